@@ -26,6 +26,17 @@ func (a *Action) RespKey() string {
 
 // Encode 签名并生成请求Body
 func (a *Action) Encode(c *Client) (string, error) {
+	return a.encode(c, nil)
+}
+
+// EncodeWithForm 签名并生成请求Query (用于文件上传)
+//
+//	formData 为 multipart 表单中的普通字段，参与签名但不出现在Query中；文件字段不参与签名
+func (a *Action) EncodeWithForm(c *Client, formData map[string]string) (string, error) {
+	return a.encode(c, formData)
+}
+
+func (a *Action) encode(c *Client, formData map[string]string) (string, error) {
 	if c.prvKey == nil {
 		return "", errors.New("private key is nil (forgotten configure?)")
 	}
@@ -40,6 +51,12 @@ func (a *Action) Encode(c *Client) (string, error) {
 	v.Set("timestamp", time.Now().In(time.Local).Format("2006-01-02 15:04:05"))
 	v.Set("version", "1.0")
 
+	// 公钥证书模式
+	if c.cert != nil {
+		v.Set("app_cert_sn", c.cert.appSN)
+		v.Set("alipay_root_cert_sn", c.cert.rootSN)
+	}
+
 	for key, val := range a.params {
 		v.Set(key, val)
 	}
@@ -62,7 +79,18 @@ func (a *Action) Encode(c *Client) (string, error) {
 		v.Set("biz_content", bizContent)
 	}
 
-	sign, err := c.prvKey.Sign(crypto.SHA256, []byte(v.Encode("=", "&", value.WithEmptyMode(value.EmptyIgnore))))
+	signV := v
+	if len(formData) != 0 {
+		signV = make(V, len(v)+len(formData))
+		for key, val := range v {
+			signV.Set(key, val)
+		}
+		for key, val := range formData {
+			signV.Set(key, val)
+		}
+	}
+
+	sign, err := c.prvKey.Sign(crypto.SHA256, []byte(signV.Encode("=", "&", value.WithEmptyMode(value.EmptyIgnore))))
 	if err != nil {
 		return "", err
 	}
@@ -172,6 +200,7 @@ func WithV3AppAuthToken(token string) V3HeaderOption {
 	}
 }
 
+// WithV3RootCertSN 设置支付宝根证书SN (使用 WithV3CertMode 时无需设置)
 func WithV3RootCertSN(sn string) V3HeaderOption {
 	return func(h http.Header) {
 		h.Set(HeaderRootCertSN, sn)
@@ -0,0 +1,110 @@
+package alipay
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal"
+	"github.com/yiigo/sdk-go/internal/value"
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+// testVerifyQuery 模拟支付宝按 Query + 表单字段 验签
+func testVerifyQuery(key *xcrypto.PublicKey, query url.Values, formData map[string]string) error {
+	sign, err := base64.StdEncoding.DecodeString(query.Get("sign"))
+	if err != nil {
+		return err
+	}
+
+	v := V{}
+	for k := range query {
+		if k != "sign" {
+			v.Set(k, query.Get(k))
+		}
+	}
+	for k, s := range formData {
+		v.Set(k, s)
+	}
+	return key.Verify(crypto.SHA256, []byte(v.Encode("=", "&", value.WithEmptyMode(value.EmptyIgnore))), sign)
+}
+
+func TestEncodeWithForm(t *testing.T) {
+	prvKey, pubKey := testRSAKeyPair(t)
+
+	c := NewClient(testAppID, testAESKey, WithPrivateKey(prvKey))
+
+	formData := map[string]string{"image_type": "jpg", "image_name": "a.jpg"}
+
+	query, err := NewAction("alipay.offline.material.image.upload").EncodeWithForm(c, formData)
+	assert.Nil(t, err)
+
+	v, err := url.ParseQuery(query)
+	assert.Nil(t, err)
+	assert.Equal(t, "alipay.offline.material.image.upload", v.Get("method"))
+
+	// 表单字段不出现在Query中
+	assert.False(t, v.Has("image_type"))
+	assert.False(t, v.Has("image_name"))
+
+	// 签名包含表单字段
+	assert.Nil(t, testVerifyQuery(pubKey, v, formData))
+	assert.NotNil(t, testVerifyQuery(pubKey, v, nil))
+
+	// 签名不包含文件字段
+	assert.NotNil(t, testVerifyQuery(pubKey, v, map[string]string{"image_type": "jpg", "image_name": "a.jpg", "image_content": "0123456789"}))
+}
+
+func TestUploadParts(t *testing.T) {
+	appKey, appPubKey := testRSAKeyPair(t)
+	alipayKey, alipayPubKey := testRSAKeyPair(t)
+
+	content := bytes.Repeat([]byte("0123456789"), 1024)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseMultipartForm(1<<20))
+
+		// 文本字段
+		formData := make(map[string]string)
+		for k, vals := range r.MultipartForm.Value {
+			formData[k] = vals[0]
+		}
+		assert.Equal(t, map[string]string{"image_type": "jpg", "image_name": "a.jpg"}, formData)
+		assert.Nil(t, testVerifyQuery(appPubKey, r.URL.Query(), formData))
+
+		// 文件字段
+		fh := r.MultipartForm.File["image_content"]
+		assert.Len(t, fh, 1)
+		f, err := fh[0].Open()
+		assert.Nil(t, err)
+		b, err := io.ReadAll(f)
+		assert.Nil(t, err)
+		assert.Equal(t, content, b)
+
+		data := `{"code":"10000","msg":"Success","image_id":"mOJ6hqLvT6uDmmNbqtQ8bwAAACMAAQED","image_url":"https://oalipay-dl-django.alicdn.com/rest/1.0/image?fileIds=mOJ6hqLvT6uDmmNbqtQ8bwAAACMAAQED"}`
+		sign, err := alipayKey.Sign(crypto.SHA256, []byte(data))
+		assert.Nil(t, err)
+
+		w.Header().Set(internal.HeaderContentType, internal.ContentJSON)
+		w.Write([]byte(`{"alipay_offline_material_image_upload_response":` + data + `,"sign":"` + base64.StdEncoding.EncodeToString(sign) + `"}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(testAppID, testAESKey, WithPrivateKey(appKey), WithPublicKey(alipayPubKey))
+	c.gateway = srv.URL
+
+	parts := []*FormPart{{FieldName: "image_content", FileName: "a.jpg", Reader: bytes.NewReader(content)}}
+	ret, err := c.UploadParts(context.Background(), "alipay.offline.material.image.upload", parts, map[string]string{"image_type": "jpg", "image_name": "a.jpg"})
+	assert.Nil(t, err)
+	assert.Equal(t, "mOJ6hqLvT6uDmmNbqtQ8bwAAACMAAQED", ret.Get("image_id").String())
+	assert.True(t, strings.HasPrefix(ret.Get("image_url").String(), "https://"))
+}
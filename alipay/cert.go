@@ -0,0 +1,187 @@
+package alipay
+
+import (
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+// CertMode 公钥证书模式
+//
+//	[参考](https://opendocs.alipay.com/common/02kdnc)
+//	支付宝公钥证书按SN保存，证书轮换期间可通过 AddAlipayCert 同时加载新旧证书
+type CertMode struct {
+	appSN   string
+	rootSN  string
+	pubSN   string
+	pubKey  *xcrypto.PublicKey
+	pubKeys map[string]*xcrypto.PublicKey // alipay_cert_sn -> 支付宝公钥
+	mutex   sync.RWMutex
+}
+
+// AppCertSN 返回应用公钥证书SN (app_cert_sn)
+func (cm *CertMode) AppCertSN() string {
+	return cm.appSN
+}
+
+// RootCertSN 返回支付宝根证书SN (alipay_root_cert_sn)
+func (cm *CertMode) RootCertSN() string {
+	return cm.rootSN
+}
+
+// AlipayCertSN 返回支付宝公钥证书SN (alipay_cert_sn)
+func (cm *CertMode) AlipayCertSN() string {
+	return cm.pubSN
+}
+
+// AddAlipayCert 加载支付宝公钥证书 (alipayCertPublicKey_RSA2.crt)，证书轮换时新旧证书可同时使用
+func (cm *CertMode) AddAlipayCert(alipayCert []byte) error {
+	sn, pubKey, err := parseAlipayCert(alipayCert)
+	if err != nil {
+		return err
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.pubKeys[sn] = pubKey
+	return nil
+}
+
+// verifyKey 根据响应中的支付宝公钥证书SN返回验签公钥，SN为空时使用初始加载的证书
+func (cm *CertMode) verifyKey(sn string) (*xcrypto.PublicKey, error) {
+	if len(sn) == 0 {
+		return cm.pubKey, nil
+	}
+
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	pubKey, ok := cm.pubKeys[sn]
+	if !ok {
+		return nil, fmt.Errorf("alipay cert not found, alipay_cert_sn = %s (forgotten AddAlipayCert?)", sn)
+	}
+	return pubKey, nil
+}
+
+// NewCertMode 通过PEM证书生成公钥证书模式配置
+//
+//	appCert：应用公钥证书 (appCertPublicKey_xxx.crt)
+//	alipayCert：支付宝公钥证书 (alipayCertPublicKey_RSA2.crt)
+//	rootCert：支付宝根证书 (alipayRootCert.crt)
+func NewCertMode(appCert, alipayCert, rootCert []byte) (*CertMode, error) {
+	appSN, err := CertSN(appCert)
+	if err != nil {
+		return nil, fmt.Errorf("app cert: %w", err)
+	}
+
+	pubSN, pubKey, err := parseAlipayCert(alipayCert)
+	if err != nil {
+		return nil, err
+	}
+
+	rootSN, err := RootCertSN(rootCert)
+	if err != nil {
+		return nil, fmt.Errorf("root cert: %w", err)
+	}
+
+	cm := &CertMode{
+		appSN:  appSN,
+		rootSN: rootSN,
+		pubSN:  pubSN,
+		pubKey: pubKey,
+		pubKeys: map[string]*xcrypto.PublicKey{
+			pubSN: pubKey,
+		},
+	}
+	return cm, nil
+}
+
+// NewCertModeFromFile 通过PEM证书文件生成公钥证书模式配置
+func NewCertModeFromFile(appCertFile, alipayCertFile, rootCertFile string) (*CertMode, error) {
+	appCert, err := os.ReadFile(appCertFile)
+	if err != nil {
+		return nil, err
+	}
+	alipayCert, err := os.ReadFile(alipayCertFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCert, err := os.ReadFile(rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewCertMode(appCert, alipayCert, rootCert)
+}
+
+// CertSN 计算证书SN：MD5(issuer + serial_number)
+func CertSN(pemBlock []byte) (string, error) {
+	cert, err := parseCert(pemBlock)
+	if err != nil {
+		return "", err
+	}
+	return certSN(cert), nil
+}
+
+// RootCertSN 计算根证书SN：证书链中RSA签名的证书SN以「_」拼接
+func RootCertSN(pemBlock []byte) (string, error) {
+	sns := make([]string, 0)
+
+	for rest := pemBlock; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", err
+		}
+		// 仅RSA签名的证书 (排除国密等)
+		switch cert.SignatureAlgorithm {
+		case x509.SHA1WithRSA, x509.SHA256WithRSA:
+			sns = append(sns, certSN(cert))
+		}
+	}
+	if len(sns) == 0 {
+		return "", errors.New("no rsa certificate is found")
+	}
+	return strings.Join(sns, "_"), nil
+}
+
+func parseAlipayCert(pemBlock []byte) (string, *xcrypto.PublicKey, error) {
+	cert, err := parseCert(pemBlock)
+	if err != nil {
+		return "", nil, fmt.Errorf("alipay cert: %w", err)
+	}
+	if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+		return "", nil, errors.New("alipay cert: public key is not rsa")
+	}
+	pubKey, err := xcrypto.NewPublicKeyFromDerBlock(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	if err != nil {
+		return "", nil, fmt.Errorf("alipay cert: %w", err)
+	}
+	return certSN(cert), pubKey, nil
+}
+
+func parseCert(pemBlock []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(pemBlock)
+	if block == nil {
+		return nil, errors.New("no PEM data is found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func certSN(cert *x509.Certificate) string {
+	h := md5.Sum([]byte(cert.Issuer.String() + cert.SerialNumber.String()))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,103 @@
+package alipay
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+// testdata 下的证书为测试自签证书：
+//
+//	alipayRootCert.crt 包含 RSA(SHA256)、ECDSA、RSA(SHA1) 三个根证书，其中 ECDSA 证书模拟国密根证书
+//	期望SN通过 openssl 独立计算：md5(RFC2253格式的issuer + 十进制serial)
+const (
+	testAppCertSN       = "f0d955747e6c1359ea8e8b3f4fd49cc6"
+	testAlipayCertSN    = "91d633ebf8dc783aa1cac47815506b87"
+	testAlipayNewCertSN = "b349b6d2842c340f940d3f06f5d3df96"
+	testRootCertSN      = "307737e83900ef12c970ac7d11241899_2dd3b0c69139241a813ba9c635de1d19"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	b, err := os.ReadFile("testdata/" + name)
+	assert.Nil(t, err)
+	return b
+}
+
+func TestCertSN(t *testing.T) {
+	sn, err := CertSN(readTestdata(t, "appCertPublicKey.crt"))
+	assert.Nil(t, err)
+	assert.Equal(t, testAppCertSN, sn)
+
+	sn, err = CertSN(readTestdata(t, "alipayCertPublicKey_RSA2.crt"))
+	assert.Nil(t, err)
+	assert.Equal(t, testAlipayCertSN, sn)
+
+	_, err = CertSN([]byte("invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestRootCertSN(t *testing.T) {
+	root := readTestdata(t, "alipayRootCert.crt")
+
+	// 排除非RSA签名的根证书
+	sn, err := RootCertSN(root)
+	assert.Nil(t, err)
+	assert.Equal(t, testRootCertSN, sn)
+
+	// 仅包含非RSA签名的根证书
+	_, rest := pem.Decode(root)
+	ecBlock, _ := pem.Decode(rest)
+	_, err = RootCertSN(pem.EncodeToMemory(ecBlock))
+	assert.NotNil(t, err)
+}
+
+func TestCertModeRotation(t *testing.T) {
+	cm, err := NewCertModeFromFile("testdata/appCertPublicKey.crt", "testdata/alipayCertPublicKey_RSA2.crt", "testdata/alipayRootCert.crt")
+	assert.Nil(t, err)
+	assert.Equal(t, testAppCertSN, cm.AppCertSN())
+	assert.Equal(t, testAlipayCertSN, cm.AlipayCertSN())
+	assert.Equal(t, testRootCertSN, cm.RootCertSN())
+
+	c := NewClient("2021000000000000", "", WithCertMode(cm))
+
+	oldKey, err := xcrypto.NewPrivateKeyFromPemFile(xcrypto.RSA_PKCS8, "testdata/alipay_private_key.pem")
+	assert.Nil(t, err)
+	newKey, err := xcrypto.NewPrivateKeyFromPemFile(xcrypto.RSA_PKCS8, "testdata/alipay_private_key_new.pem")
+	assert.Nil(t, err)
+
+	// 模拟支付宝使用 alipay_cert_sn 对应证书的私钥签名应答
+	resp := func(key *xcrypto.PrivateKey, sn string) []byte {
+		data := `{"code":"10000","msg":"Success","trade_no":"2013112011001004330000121536"}`
+		sign, err := key.Sign(crypto.SHA256, []byte(data))
+		assert.Nil(t, err)
+		return []byte(`{"alipay_trade_query_response":` + data + `,"alipay_cert_sn":"` + sn + `","sign":"` + base64.StdEncoding.EncodeToString(sign) + `"}`)
+	}
+
+	_, err = c.verifyResp("alipay_trade_query_response", http.Header{}, resp(oldKey, testAlipayCertSN))
+	assert.Nil(t, err)
+
+	// 未加载新证书
+	_, err = c.verifyResp("alipay_trade_query_response", http.Header{}, resp(newKey, testAlipayNewCertSN))
+	assert.NotNil(t, err)
+
+	// 轮换期间新旧证书均可验签
+	assert.Nil(t, cm.AddAlipayCert(readTestdata(t, "alipayCertPublicKey_RSA2_new.crt")))
+
+	ret, err := c.verifyResp("alipay_trade_query_response", http.Header{}, resp(newKey, testAlipayNewCertSN))
+	assert.Nil(t, err)
+	assert.Equal(t, "2013112011001004330000121536", ret.Get("trade_no").String())
+
+	_, err = c.verifyResp("alipay_trade_query_response", http.Header{}, resp(oldKey, testAlipayCertSN))
+	assert.Nil(t, err)
+
+	// SN与签名证书不匹配
+	_, err = c.verifyResp("alipay_trade_query_response", http.Header{}, resp(oldKey, testAlipayNewCertSN))
+	assert.NotNil(t, err)
+}
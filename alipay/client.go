@@ -9,6 +9,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
@@ -26,6 +28,8 @@ type Client struct {
 	aesKey  string
 	prvKey  *xcrypto.PrivateKey
 	pubKey  *xcrypto.PublicKey
+	cert    *CertMode
+	retry   int // 文件上传网络错误时的重试次数
 	client  *resty.Client
 	logger  func(ctx context.Context, err error, data map[string]string)
 }
@@ -69,7 +73,7 @@ func (c *Client) Do(ctx context.Context, method string, options ...ActionOption)
 	}
 
 	// 签名校验
-	ret, err := c.verifyResp(action.RespKey(), resp.Body())
+	ret, err := c.verifyResp(action.RespKey(), resp.Header(), resp.Body())
 	if err != nil {
 		log.SetError(err)
 		return internal.Fail(err)
@@ -78,7 +82,7 @@ func (c *Client) Do(ctx context.Context, method string, options ...ActionOption)
 	// JSON串，无需解密
 	if strings.HasPrefix(ret.String(), "{") {
 		if code := ret.Get("code").String(); code != CodeOK {
-			return internal.Fail(fmt.Errorf("%s | %s (sub_code = %s, sub_msg = %s)", code, ret.Get("msg").String(), ret.Get("sub_code").String(), ret.Get("sub_msg").String()))
+			return internal.Fail(newError(ret, resp.Header()))
 		}
 		return ret, nil
 	}
@@ -98,83 +102,52 @@ func (c *Client) Do(ctx context.Context, method string, options ...ActionOption)
 //
 //	[参考](https://opendocs.alipay.com/apis/api_4/alipay.merchant.item.file.upload)
 func (c *Client) Upload(ctx context.Context, method string, fieldName, filePath string, formData map[string]string, options ...ActionOption) (gjson.Result, error) {
-	log := internal.NewReqLog(http.MethodPost, c.gateway)
-	defer log.Do(ctx, c.logger)
-
-	action := NewAction(method, options...)
-
-	query, err := action.Encode(c)
-	if err != nil {
-		log.SetError(err)
-		return internal.Fail(err)
-	}
-	log.Set("query", query)
-
-	resp, err := c.client.R().
-		SetContext(ctx).
-		SetHeader(internal.HeaderAccept, internal.ContentJSON).
-		SetFile(fieldName, filePath).
-		SetMultipartFormData(formData).
-		Post(c.gateway + "?" + query)
-	if err != nil {
-		log.SetError(err)
-		return internal.Fail(err)
-	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
-
-	// 签名校验
-	ret, err := c.verifyResp(action.RespKey(), resp.Body())
+	f, err := os.Open(filePath)
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
+	defer f.Close()
 
-	// JSON串，无需解密
-	if strings.HasPrefix(ret.String(), "{") {
-		if code := ret.Get("code").String(); code != CodeOK {
-			return internal.Fail(fmt.Errorf("%s | %s (sub_code = %s, sub_msg = %s)", code, ret.Get("msg").String(), ret.Get("sub_code").String(), ret.Get("sub_msg").String()))
-		}
-		return ret, nil
-	}
-
-	// 非JSON串，需解密
-	data, err := c.Decrypt(ret.String())
-	if err != nil {
-		log.SetError(err)
-		return internal.Fail(err)
-	}
-	log.Set("decrypt", string(data))
-
-	return gjson.ParseBytes(data), nil
+	return c.UploadParts(ctx, method, []*FormPart{{FieldName: fieldName, FileName: filepath.Base(filePath), Reader: f}}, formData, options...)
 }
 
-// UploadWithReader 文件上传
+// UploadWithReader 文件上传 (reader 实现 io.Seeker 时支持失败重试)
 //
 //	[参考](https://opendocs.alipay.com/apis/api_4/alipay.merchant.item.file.upload)
 func (c *Client) UploadWithReader(ctx context.Context, method string, fieldName, fileName string, reader io.Reader, formData map[string]string, options ...ActionOption) (gjson.Result, error) {
+	return c.UploadParts(ctx, method, []*FormPart{{FieldName: fieldName, FileName: fileName, Reader: reader}}, formData, options...)
+}
+
+// UploadParts 文件上传 (支持多文件及指定各部分的 Content-Type)
+//
+//	表单以流的方式发送，文件内容无需整体载入内存；formData 中的普通字段参与签名
+//	[参考](https://opendocs.alipay.com/apis/api_4/alipay.merchant.item.file.upload)
+func (c *Client) UploadParts(ctx context.Context, method string, parts []*FormPart, formData map[string]string, options ...ActionOption) (gjson.Result, error) {
 	log := internal.NewReqLog(http.MethodPost, c.gateway)
 	defer log.Do(ctx, c.logger)
 
 	action := NewAction(method, options...)
 
-	query, err := action.Encode(c)
+	query, err := action.EncodeWithForm(c, formData)
 	if err != nil {
 		log.SetError(err)
 		return internal.Fail(err)
 	}
 	log.Set("query", query)
 
-	resp, err := c.client.R().
-		SetContext(ctx).
-		SetHeader(internal.HeaderAccept, internal.ContentJSON).
-		SetMultipartField(fieldName, fileName, "", reader).
-		SetMultipartFormData(formData).
-		Post(c.gateway + "?" + query)
+	form := internal.NewMultipartForm()
+	for k, v := range formData {
+		form.Field(k, v)
+	}
+	for _, p := range parts {
+		form.Part(p)
+	}
+
+	resp, err := postMultipart(ctx, c.client, c.gateway+"?"+query, form, c.retry, func() (http.Header, error) {
+		header := http.Header{}
+		header.Set(internal.HeaderAccept, internal.ContentJSON)
+		return header, nil
+	})
 	if err != nil {
 		log.SetError(err)
 		return internal.Fail(err)
@@ -187,7 +160,7 @@ func (c *Client) UploadWithReader(ctx context.Context, method string, fieldName,
 	}
 
 	// 签名校验
-	ret, err := c.verifyResp(action.RespKey(), resp.Body())
+	ret, err := c.verifyResp(action.RespKey(), resp.Header(), resp.Body())
 	if err != nil {
 		log.SetError(err)
 		return internal.Fail(err)
@@ -196,7 +169,7 @@ func (c *Client) UploadWithReader(ctx context.Context, method string, fieldName,
 	// JSON串，无需解密
 	if strings.HasPrefix(ret.String(), "{") {
 		if code := ret.Get("code").String(); code != CodeOK {
-			return internal.Fail(fmt.Errorf("%s | %s (sub_code = %s, sub_msg = %s)", code, ret.Get("msg").String(), ret.Get("sub_code").String(), ret.Get("sub_msg").String()))
+			return internal.Fail(newError(ret, resp.Header()))
 		}
 		return ret, nil
 	}
@@ -212,7 +185,7 @@ func (c *Client) UploadWithReader(ctx context.Context, method string, fieldName,
 	return gjson.ParseBytes(data), nil
 }
 
-func (c *Client) verifyResp(key string, body []byte) (gjson.Result, error) {
+func (c *Client) verifyResp(key string, header http.Header, body []byte) (gjson.Result, error) {
 	if c.pubKey == nil {
 		return internal.Fail(errors.New("public key is nil (forgotten configure?)"))
 	}
@@ -229,21 +202,24 @@ func (c *Client) verifyResp(key string, body []byte) (gjson.Result, error) {
 		xhash = crypto.SHA1
 	}
 
+	pubKey := c.pubKey
+	// 公钥证书模式，使用 alipay_cert_sn 对应的支付宝公钥证书验签
+	if c.cert != nil {
+		if pubKey, err = c.cert.verifyKey(ret.Get("alipay_cert_sn").String()); err != nil {
+			return internal.Fail(err)
+		}
+	}
+
 	if errResp := ret.Get("error_response"); errResp.Exists() {
-		if err = c.pubKey.Verify(xhash, []byte(errResp.Raw), signByte); err != nil {
+		if err = pubKey.Verify(xhash, []byte(errResp.Raw), signByte); err != nil {
 			return internal.Fail(err)
 		}
 
-		return internal.Fail(fmt.Errorf("%s | %s (sub_code = %s, sub_msg = %s)",
-			errResp.Get("code").String(),
-			errResp.Get("msg").String(),
-			errResp.Get("sub_code").String(),
-			errResp.Get("sub_msg").String(),
-		))
+		return internal.Fail(newError(errResp, header))
 	}
 
 	resp := ret.Get(key)
-	if err = c.pubKey.Verify(xhash, []byte(resp.Raw), signByte); err != nil {
+	if err = pubKey.Verify(xhash, []byte(resp.Raw), signByte); err != nil {
 		return internal.Fail(err)
 	}
 	return resp, nil
@@ -355,6 +331,21 @@ func WithPublicKey(key *xcrypto.PublicKey) Option {
 	}
 }
 
+// WithCertMode 设置公钥证书模式 (无需再设置平台RSA公钥)
+func WithCertMode(cm *CertMode) Option {
+	return func(c *Client) {
+		c.cert = cm
+		c.pubKey = cm.pubKey
+	}
+}
+
+// WithUploadRetry 设置文件上传因网络错误失败时的重试次数 (默认：0；文件内容须实现 io.Seeker)
+func WithUploadRetry(n int) Option {
+	return func(c *Client) {
+		c.retry = n
+	}
+}
+
 // WithLogger 设置日志记录
 func WithLogger(fn func(ctx context.Context, err error, data map[string]string)) Option {
 	return func(c *Client) {
@@ -11,6 +11,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,6 +32,9 @@ type ClientV3 struct {
 	aesKey string
 	prvKey *xcrypto.PrivateKey
 	pubKey *xcrypto.PublicKey
+	cert   *CertMode
+	window time.Duration
+	retry  int // 文件上传网络错误时的重试次数
 	client *resty.Client
 	logger func(ctx context.Context, err error, data map[string]string)
 }
@@ -83,6 +89,10 @@ func (c *ClientV3) do(ctx context.Context, method, path string, query url.Values
 		}
 	}
 
+	if c.cert != nil && len(header.Get(HeaderRootCertSN)) == 0 {
+		header.Set(HeaderRootCertSN, c.cert.rootSN)
+	}
+
 	authStr, err := c.Authorization(method, path, query, body, header)
 	if err != nil {
 		log.SetError(err)
@@ -106,12 +116,18 @@ func (c *ClientV3) do(ctx context.Context, method, path string, query url.Values
 		return nil, err
 	}
 
+	if resp.StatusCode() >= 400 {
+		err = newV3Error(gjson.ParseBytes(resp.Body()), resp.Header())
+		log.SetError(err)
+		return nil, err
+	}
+
 	ret := &APIResult{
 		Code: resp.StatusCode(),
 		Body: gjson.ParseBytes(resp.Body()),
 	}
 	// 如果是加密请求，需要解密
-	if resp.StatusCode() < 400 && len(resp.Body()) != 0 && !bytes.HasPrefix(resp.Body(), []byte("{")) {
+	if len(resp.Body()) != 0 && !bytes.HasPrefix(resp.Body(), []byte("{")) {
 		data, err := c.Decrypt(string(resp.Body()))
 		if err != nil {
 			log.SetError(err)
@@ -162,58 +178,27 @@ func (c *ClientV3) PostEncrypt(ctx context.Context, path string, params X, optio
 //
 //	[参考](https://opendocs.alipay.com/open-v3/054oog?pathHash=7834d743)
 func (c *ClientV3) Upload(ctx context.Context, reqPath, fieldName, filePath, bizData string, options ...V3HeaderOption) (*APIResult, error) {
-	reqID := uuid.NewString()
-	reqURL := c.url(reqPath, nil)
-
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, c.logger)
-
-	log.Set("biz_data", bizData)
-
-	reqHeader := http.Header{}
-	reqHeader.Set(HeaderRequestID, reqID)
-	for _, f := range options {
-		f(reqHeader)
-	}
-	authStr, err := c.Authorization(http.MethodPost, reqPath, nil, []byte(bizData), reqHeader)
+	f, err := os.Open(filePath)
 	if err != nil {
-		log.SetError(err)
-		return nil, err
-	}
-	reqHeader.Set(internal.HeaderAuthorization, authStr)
-	log.SetReqHeader(reqHeader)
-
-	resp, err := c.client.R().
-		SetContext(ctx).
-		SetHeaderMultiValues(reqHeader).
-		SetFile(fieldName, filePath).
-		SetMultipartField("data", "", internal.ContentJSON, strings.NewReader(bizData)).
-		Post(reqURL)
-	if err != nil {
-		log.SetError(err)
-		return nil, err
-	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-
-	// 签名校验
-	if err = c.Verify(resp.Header(), resp.Body()); err != nil {
-		log.SetError(err)
 		return nil, err
 	}
+	defer f.Close()
 
-	ret := &APIResult{
-		Code: resp.StatusCode(),
-		Body: gjson.ParseBytes(resp.Body()),
-	}
-	return ret, nil
+	return c.UploadParts(ctx, reqPath, []*FormPart{{FieldName: fieldName, FileName: filepath.Base(filePath), Reader: f}}, bizData, options...)
 }
 
-// UploadWithReader 文件上传
+// UploadWithReader 文件上传 (reader 实现 io.Seeker 时支持失败重试)
 //
 //	[参考](https://opendocs.alipay.com/open-v3/054oog?pathHash=7834d743)
 func (c *ClientV3) UploadWithReader(ctx context.Context, reqPath, fieldName, fileName string, reader io.Reader, bizData string, options ...V3HeaderOption) (*APIResult, error) {
+	return c.UploadParts(ctx, reqPath, []*FormPart{{FieldName: fieldName, FileName: fileName, Reader: reader}}, bizData, options...)
+}
+
+// UploadParts 文件上传 (支持多文件及指定各部分的 Content-Type)
+//
+//	表单以流的方式发送，文件内容无需整体载入内存；bizData 作为 data 字段参与签名
+//	[参考](https://opendocs.alipay.com/open-v3/054oog?pathHash=7834d743)
+func (c *ClientV3) UploadParts(ctx context.Context, reqPath string, parts []*FormPart, bizData string, options ...V3HeaderOption) (*APIResult, error) {
 	reqID := uuid.NewString()
 	reqURL := c.url(reqPath, nil)
 
@@ -222,25 +207,26 @@ func (c *ClientV3) UploadWithReader(ctx context.Context, reqPath, fieldName, fil
 
 	log.Set("biz_data", bizData)
 
-	reqHeader := http.Header{}
-	reqHeader.Set(HeaderRequestID, reqID)
-	for _, f := range options {
-		f(reqHeader)
-	}
-	authStr, err := c.Authorization(http.MethodPost, reqPath, nil, []byte(bizData), reqHeader)
-	if err != nil {
-		log.SetError(err)
-		return nil, err
-	}
-	reqHeader.Set(internal.HeaderAuthorization, authStr)
-	log.SetReqHeader(reqHeader)
+	form := internal.NewMultipartForm(parts...)
+	form.Part(&FormPart{FieldName: "data", ContentType: internal.ContentJSON, Reader: strings.NewReader(bizData)})
 
-	resp, err := c.client.R().
-		SetContext(ctx).
-		SetHeaderMultiValues(reqHeader).
-		SetMultipartField(fieldName, fileName, "", reader).
-		SetMultipartField("data", "", internal.ContentJSON, strings.NewReader(bizData)).
-		Post(reqURL)
+	resp, err := postMultipart(ctx, c.client, reqURL, form, c.retry, func() (http.Header, error) {
+		reqHeader := http.Header{}
+		reqHeader.Set(HeaderRequestID, reqID)
+		if c.cert != nil {
+			reqHeader.Set(HeaderRootCertSN, c.cert.rootSN)
+		}
+		for _, f := range options {
+			f(reqHeader)
+		}
+		authStr, err := c.Authorization(http.MethodPost, reqPath, nil, []byte(bizData), reqHeader)
+		if err != nil {
+			return nil, err
+		}
+		reqHeader.Set(internal.HeaderAuthorization, authStr)
+		log.SetReqHeader(reqHeader)
+		return reqHeader, nil
+	})
 	if err != nil {
 		log.SetError(err)
 		return nil, err
@@ -255,6 +241,12 @@ func (c *ClientV3) UploadWithReader(ctx context.Context, reqPath, fieldName, fil
 		return nil, err
 	}
 
+	if resp.StatusCode() >= 400 {
+		err = newV3Error(gjson.ParseBytes(resp.Body()), resp.Header())
+		log.SetError(err)
+		return nil, err
+	}
+
 	ret := &APIResult{
 		Code: resp.StatusCode(),
 		Body: gjson.ParseBytes(resp.Body()),
@@ -268,7 +260,12 @@ func (c *ClientV3) Authorization(method, path string, query url.Values, body []b
 		return "", errors.New("private key not found (forgotten configure?)")
 	}
 
-	authStr := fmt.Sprintf("app_id=%s,nonce=%s,timestamp=%d", c.appid, internal.Nonce(32), time.Now().UnixMilli())
+	authStr := "app_id=" + c.appid
+	// 公钥证书模式
+	if c.cert != nil {
+		authStr += ",app_cert_sn=" + c.cert.appSN
+	}
+	authStr += fmt.Sprintf(",nonce=%s,timestamp=%d", internal.Nonce(32), time.Now().UnixMilli())
 
 	var builder strings.Builder
 
@@ -305,6 +302,16 @@ func (c *ClientV3) Verify(header http.Header, body []byte) error {
 		return errors.New("public key not found (forgotten configure?)")
 	}
 
+	pubKey := c.pubKey
+	// 公钥证书模式，使用 alipay-sn 对应的支付宝公钥证书验签
+	if c.cert != nil {
+		key, err := c.cert.verifyKey(header.Get(HeaderSN))
+		if err != nil {
+			return err
+		}
+		pubKey = key
+	}
+
 	signByte, err := base64.StdEncoding.DecodeString(header.Get(HeaderSignature))
 	if err != nil {
 		return err
@@ -324,7 +331,50 @@ func (c *ClientV3) Verify(header http.Header, body []byte) error {
 		builder.WriteString("\n")
 	}
 
-	return c.pubKey.Verify(crypto.SHA256, []byte(builder.String()), signByte)
+	return pubKey.Verify(crypto.SHA256, []byte(builder.String()), signByte)
+}
+
+// ParseNotify 解析异步通知 (验签、校验时间戳、解密)
+//
+//	[参考](https://opendocs.alipay.com/open-v3/05pf4k)
+func (c *ClientV3) ParseNotify(r *http.Request) (gjson.Result, error) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return internal.Fail(err)
+	}
+	r.Body.Close()
+
+	// 时间戳(毫秒)校验，防止重放
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return internal.Fail(fmt.Errorf("invalid header timestamp: %w", err))
+	}
+	if d := time.Since(time.UnixMilli(timestamp)); c.window > 0 && (d > c.window || d < -c.window) {
+		return internal.Fail(fmt.Errorf("header timestamp expired, timestamp = %d", timestamp))
+	}
+
+	if err = c.Verify(r.Header, b); err != nil {
+		return internal.Fail(err)
+	}
+
+	// 加密通知，需解密
+	if len(r.Header.Get(HeaderEncryptType)) != 0 {
+		data, err := c.Decrypt(string(b))
+		if err != nil {
+			return internal.Fail(fmt.Errorf("notify decrypt error: %w", err))
+		}
+		b = data
+	}
+	return gjson.ParseBytes(b), nil
+}
+
+// DecodeNotify 解析异步通知到v (同 ParseNotify)
+func (c *ClientV3) DecodeNotify(r *http.Request, v any) error {
+	ret, err := c.ParseNotify(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(ret.Raw), v)
 }
 
 // Encrypt 数据加密
@@ -377,6 +427,28 @@ func WithV3PublicKey(key *xcrypto.PublicKey) V3Option {
 	}
 }
 
+// WithV3CertMode 设置公钥证书模式 (无需再设置平台RSA公钥)
+func WithV3CertMode(cm *CertMode) V3Option {
+	return func(c *ClientV3) {
+		c.cert = cm
+		c.pubKey = cm.pubKey
+	}
+}
+
+// WithV3NotifyWindow 设置异步通知时间戳的有效期 (默认：5分钟；<=0 表示不校验)
+func WithV3NotifyWindow(d time.Duration) V3Option {
+	return func(c *ClientV3) {
+		c.window = d
+	}
+}
+
+// WithV3UploadRetry 设置文件上传因网络错误失败时的重试次数 (默认：0；文件内容须实现 io.Seeker)
+func WithV3UploadRetry(n int) V3Option {
+	return func(c *ClientV3) {
+		c.retry = n
+	}
+}
+
 // WithV3Logger 设置日志记录
 func WithV3Logger(fn func(ctx context.Context, err error, data map[string]string)) V3Option {
 	return func(c *ClientV3) {
@@ -390,6 +462,7 @@ func NewClientV3(appid, aesKey string, options ...V3Option) *ClientV3 {
 		host:   "https://openapi.alipay.com",
 		appid:  appid,
 		aesKey: aesKey,
+		window: 5 * time.Minute,
 		client: internal.NewClient(),
 	}
 	for _, f := range options {
@@ -404,6 +477,7 @@ func NewSandboxV3(appid, aesKey string, options ...V3Option) *ClientV3 {
 		host:   "http://openapi.sandbox.dl.alipaydev.com",
 		appid:  appid,
 		aesKey: aesKey,
+		window: 5 * time.Minute,
 		client: internal.NewClient(),
 	}
 	for _, f := range options {
@@ -0,0 +1,90 @@
+package alipay
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+const testAESKey = "aYA0GP8JEW+D7/UFaskCWA=="
+
+func testRSAKeyPair(t *testing.T) (*xcrypto.PrivateKey, *xcrypto.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	prvKey, err := xcrypto.NewPrivateKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	assert.Nil(t, err)
+	pubKey, err := xcrypto.NewPublicKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}))
+	assert.Nil(t, err)
+	return prvKey, pubKey
+}
+
+// testV3Notify 模拟支付宝使用私钥签名的异步通知
+func testV3Notify(t *testing.T, key *xcrypto.PrivateKey, timestamp time.Time, body string, encrypt bool) *http.Request {
+	ts := strconv.FormatInt(timestamp.UnixMilli(), 10)
+	nonce := "f3a6d5e9c1b24f0a8e7d6c5b4a392817"
+
+	sign, err := key.Sign(crypto.SHA256, []byte(ts+"\n"+nonce+"\n"+body+"\n"))
+	assert.Nil(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body))
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(sign))
+	if encrypt {
+		r.Header.Set(HeaderEncryptType, "AES")
+	}
+	return r
+}
+
+func TestClientV3ParseNotify(t *testing.T) {
+	prvKey, pubKey := testRSAKeyPair(t)
+
+	c := NewClientV3("2021000000000000", testAESKey, WithV3PublicKey(pubKey))
+
+	body := `{"out_trade_no":"20150320010101001","trade_status":"TRADE_SUCCESS","total_amount":"88.88"}`
+
+	// 验签
+	ret, err := c.ParseNotify(testV3Notify(t, prvKey, time.Now(), body, false))
+	assert.Nil(t, err)
+	assert.Equal(t, "TRADE_SUCCESS", ret.Get("trade_status").String())
+
+	r := testV3Notify(t, prvKey, time.Now(), body, false)
+	r.Header.Set(HeaderNonce, "tampered")
+	_, err = c.ParseNotify(r)
+	assert.NotNil(t, err)
+
+	otherKey, _ := testRSAKeyPair(t)
+	_, err = c.ParseNotify(testV3Notify(t, otherKey, time.Now(), body, false))
+	assert.NotNil(t, err)
+
+	// 时间戳过期
+	_, err = c.ParseNotify(testV3Notify(t, prvKey, time.Now().Add(-10*time.Minute), body, false))
+	assert.NotNil(t, err)
+
+	// 不校验时间戳
+	noWindow := NewClientV3("2021000000000000", testAESKey, WithV3PublicKey(pubKey), WithV3NotifyWindow(0))
+	_, err = noWindow.ParseNotify(testV3Notify(t, prvKey, time.Now().Add(-10*time.Minute), body, false))
+	assert.Nil(t, err)
+
+	// 加密通知
+	cipher, err := c.Encrypt(body)
+	assert.Nil(t, err)
+	ret, err = c.ParseNotify(testV3Notify(t, prvKey, time.Now(), cipher, true))
+	assert.Nil(t, err)
+	assert.Equal(t, "20150320010101001", ret.Get("out_trade_no").String())
+	assert.Equal(t, "88.88", ret.Get("total_amount").String())
+}
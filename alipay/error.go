@@ -0,0 +1,85 @@
+package alipay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// 常见业务错误码 (sub_code)
+const (
+	SubCodeSystemError     = "ACQ.SYSTEM_ERROR"      // 系统错误，可重试
+	SubCodeAopSystemError  = "aop.ACQ.SYSTEM_ERROR"  // 系统错误，可重试
+	SubCodeTradeHasSuccess = "ACQ.TRADE_HAS_SUCCESS" // 交易已被支付，可视为成功
+)
+
+// CodeUnavailable 服务不可用
+const CodeUnavailable = "20000"
+
+// Error 支付宝接口错误
+//
+//	通过 errors.As 获取：
+//	var e *alipay.Error
+//	if errors.As(err, &e) { ... }
+type Error struct {
+	Code    string // 网关返回码 (v3为错误码，如：invalid-parameter)
+	Msg     string // 网关返回码描述
+	SubCode string // 业务返回码
+	SubMsg  string // 业务返回码描述
+	TraceID string // 链路ID (alipay-trace-id)
+}
+
+func (e *Error) Error() string {
+	if len(e.SubCode) == 0 && len(e.SubMsg) == 0 {
+		return fmt.Sprintf("%s | %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("%s | %s (sub_code = %s, sub_msg = %s)", e.Code, e.Msg, e.SubCode, e.SubMsg)
+}
+
+// IsRetryable 是否为可重试的错误 (如：系统错误、服务不可用)
+func IsRetryable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.SubCode {
+	case SubCodeSystemError, SubCodeAopSystemError:
+		return true
+	}
+	return e.Code == CodeUnavailable
+}
+
+// IsTradeHasSuccess 是否为交易已成功 (幂等请求可视为成功)
+func IsTradeHasSuccess(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.SubCode == SubCodeTradeHasSuccess
+}
+
+// IsConfigError 是否为配置错误 (sub_code 以 isv. 开头，如：isv.invalid-signature)，重试无法解决
+func IsConfigError(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && strings.HasPrefix(e.SubCode, "isv.")
+}
+
+// newError 根据网关返回生成错误
+func newError(ret gjson.Result, header http.Header) *Error {
+	return &Error{
+		Code:    ret.Get("code").String(),
+		Msg:     ret.Get("msg").String(),
+		SubCode: ret.Get("sub_code").String(),
+		SubMsg:  ret.Get("sub_msg").String(),
+		TraceID: header.Get(HeaderTraceID),
+	}
+}
+
+// newV3Error 根据v3的错误应答生成错误
+func newV3Error(ret gjson.Result, header http.Header) *Error {
+	return &Error{
+		Code:    ret.Get("code").String(),
+		Msg:     ret.Get("message").String(),
+		TraceID: header.Get(HeaderTraceID),
+	}
+}
@@ -0,0 +1,288 @@
+package alipay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/yiigo/sdk-go/internal"
+)
+
+// 异步通知类型 (notify_type)
+const (
+	NotifyTradeStatusSync  = "trade_status_sync"  // 交易状态同步 (支付、退款、关闭)
+	NotifyFundAuthFreeze   = "fund_auth_freeze"   // 资金授权冻结
+	NotifyFundAuthUnfreeze = "fund_auth_unfreeze" // 资金授权解冻
+)
+
+// MsgMethodMerchantPrefix 进件消息 (msg_method) 前缀，如：ant.merchant.expand.indirect.zft.passed
+const MsgMethodMerchantPrefix = "ant.merchant.expand."
+
+// 交易状态
+const (
+	TradeWaitBuyerPay = "WAIT_BUYER_PAY" // 交易创建，等待买家付款
+	TradeClosed       = "TRADE_CLOSED"   // 未付款交易超时关闭，或支付完成后全额退款
+	TradeSuccess      = "TRADE_SUCCESS"  // 交易支付成功
+	TradeFinished     = "TRADE_FINISHED" // 交易结束，不可退款
+)
+
+// 异步通知应答
+const (
+	NotifyReplySuccess = "success"
+	NotifyReplyFail    = "fail"
+)
+
+// Notify 异步通知
+type Notify struct {
+	NotifyID   string // 通知ID
+	NotifyType string // 通知类型 (普通通知)
+	NotifyTime string // 通知时间
+	MsgMethod  string // 消息类型 (消息服务，如：进件结果)
+	Params     V      // 验签后的通知参数 (不含 sign、sign_type)
+
+	bizContent []byte // biz_content (已解密)
+}
+
+// BizContent 返回 biz_content (加密通知已解密)
+func (n *Notify) BizContent() []byte {
+	return n.bizContent
+}
+
+// Decode 将 biz_content 解析到v
+func (n *Notify) Decode(v any) error {
+	return json.Unmarshal(n.bizContent, v)
+}
+
+// IsRefund 是否为退款通知 (交易状态同步中包含退款信息)
+func (n *Notify) IsRefund() bool {
+	return n.NotifyType == NotifyTradeStatusSync && (len(n.Params.Get("refund_fee")) != 0 || len(n.Params.Get("gmt_refund")) != 0)
+}
+
+// Trade 解析交易通知
+//
+//	[参考](https://opendocs.alipay.com/open/203/105286)
+func (n *Notify) Trade() (*TradeNotify, error) {
+	if n.NotifyType != NotifyTradeStatusSync {
+		return nil, fmt.Errorf("notify_type mismatch, expect = %s, actual = %s", NotifyTradeStatusSync, n.NotifyType)
+	}
+	ret := new(TradeNotify)
+	if err := n.decodeParams(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Refund 解析退款通知
+//
+//	[参考](https://opendocs.alipay.com/support/01raw4)
+func (n *Notify) Refund() (*RefundNotify, error) {
+	if !n.IsRefund() {
+		return nil, fmt.Errorf("not a refund notify, notify_type = %s", n.NotifyType)
+	}
+	ret := new(RefundNotify)
+	if err := n.decodeParams(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// FundAuth 解析资金授权(冻结/解冻)通知
+//
+//	[参考](https://opendocs.alipay.com/open/064jhh)
+func (n *Notify) FundAuth() (*FundAuthNotify, error) {
+	if n.NotifyType != NotifyFundAuthFreeze && n.NotifyType != NotifyFundAuthUnfreeze {
+		return nil, fmt.Errorf("notify_type mismatch, expect = fund_auth_*, actual = %s", n.NotifyType)
+	}
+	ret := new(FundAuthNotify)
+	if err := n.decodeParams(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Merchant 解析进件结果通知 (消息服务，biz_content)
+//
+//	[参考](https://opendocs.alipay.com/open/020tgn)
+func (n *Notify) Merchant() (*MerchantNotify, error) {
+	if !strings.HasPrefix(n.MsgMethod, MsgMethodMerchantPrefix) {
+		return nil, fmt.Errorf("msg_method mismatch, expect = %s*, actual = %s", MsgMethodMerchantPrefix, n.MsgMethod)
+	}
+	ret := &MerchantNotify{MsgMethod: n.MsgMethod}
+	if err := n.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (n *Notify) decodeParams(v any) error {
+	b, err := json.Marshal(n.Params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// TradeNotify 交易通知
+type TradeNotify struct {
+	NotifyTime        string `json:"notify_time"`
+	NotifyType        string `json:"notify_type"`
+	NotifyID          string `json:"notify_id"`
+	AppID             string `json:"app_id"`
+	AuthAppID         string `json:"auth_app_id"`
+	TradeNo           string `json:"trade_no"`
+	OutTradeNo        string `json:"out_trade_no"`
+	OutBizNo          string `json:"out_biz_no"`
+	BuyerID           string `json:"buyer_id"`
+	BuyerOpenID       string `json:"buyer_open_id"`
+	BuyerLogonID      string `json:"buyer_logon_id"`
+	SellerID          string `json:"seller_id"`
+	SellerEmail       string `json:"seller_email"`
+	TradeStatus       string `json:"trade_status"`
+	TotalAmount       string `json:"total_amount"`
+	ReceiptAmount     string `json:"receipt_amount"`
+	InvoiceAmount     string `json:"invoice_amount"`
+	BuyerPayAmount    string `json:"buyer_pay_amount"`
+	PointAmount       string `json:"point_amount"`
+	RefundFee         string `json:"refund_fee"`
+	Subject           string `json:"subject"`
+	Body              string `json:"body"`
+	GmtCreate         string `json:"gmt_create"`
+	GmtPayment        string `json:"gmt_payment"`
+	GmtRefund         string `json:"gmt_refund"`
+	GmtClose          string `json:"gmt_close"`
+	FundBillList      string `json:"fund_bill_list"`
+	PassbackParams    string `json:"passback_params"`
+	VoucherDetailList string `json:"voucher_detail_list"`
+}
+
+// RefundNotify 退款通知
+type RefundNotify struct {
+	NotifyTime   string `json:"notify_time"`
+	NotifyID     string `json:"notify_id"`
+	AppID        string `json:"app_id"`
+	TradeNo      string `json:"trade_no"`
+	OutTradeNo   string `json:"out_trade_no"`
+	OutBizNo     string `json:"out_biz_no"` // 退款请求号
+	TradeStatus  string `json:"trade_status"`
+	TotalAmount  string `json:"total_amount"`
+	RefundFee    string `json:"refund_fee"` // 总退款金额
+	GmtRefund    string `json:"gmt_refund"`
+	GmtClose     string `json:"gmt_close"`
+	BuyerID      string `json:"buyer_id"`
+	BuyerOpenID  string `json:"buyer_open_id"`
+	FundBillList string `json:"fund_bill_list"`
+}
+
+// FundAuthNotify 资金授权通知
+type FundAuthNotify struct {
+	NotifyTime          string `json:"notify_time"`
+	NotifyType          string `json:"notify_type"`
+	NotifyID            string `json:"notify_id"`
+	AppID               string `json:"app_id"`
+	AuthNo              string `json:"auth_no"`
+	OutOrderNo          string `json:"out_order_no"`
+	OperationID         string `json:"operation_id"`
+	OutRequestNo        string `json:"out_request_no"`
+	OperationType       string `json:"operation_type"`
+	Amount              string `json:"amount"`
+	Status              string `json:"status"`
+	GmtCreate           string `json:"gmt_create"`
+	GmtTrans            string `json:"gmt_trans"`
+	PayerLogonID        string `json:"payer_logon_id"`
+	PayerUserID         string `json:"payer_user_id"`
+	PayerOpenID         string `json:"payer_open_id"`
+	PayeeLogonID        string `json:"payee_logon_id"`
+	PayeeUserID         string `json:"payee_user_id"`
+	TotalFreezeAmount   string `json:"total_freeze_amount"`
+	TotalUnfreezeAmount string `json:"total_unfreeze_amount"`
+	TotalPayAmount      string `json:"total_pay_amount"`
+	RestAmount          string `json:"rest_amount"`
+	CreditAmount        string `json:"credit_amount"`
+	FundAmount          string `json:"fund_amount"`
+	PreAuthType         string `json:"pre_auth_type"`
+	TransCurrency       string `json:"trans_currency"`
+}
+
+// MerchantNotify 进件结果通知
+type MerchantNotify struct {
+	MsgMethod    string `json:"-"`
+	OrderID      string `json:"order_id"`
+	ExternalID   string `json:"external_id"`
+	MerchantName string `json:"merchant_name"`
+	Smid         string `json:"smid"`
+	CardAliasNo  string `json:"card_alias_no"`
+	Memo         string `json:"memo"`
+	Reason       string `json:"reason"`
+	ApplyTime    string `json:"apply_time"`
+}
+
+// ParseNotify 解析异步通知 (验签、校验app_id、解密biz_content)
+func (c *Client) ParseNotify(r *http.Request) (*Notify, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	// 仅验签Body中的参数，通知地址的Query不参与签名
+	v, err := c.VerifyNotify(r.PostForm)
+	if err != nil {
+		return nil, err
+	}
+	if appid := v.Get("app_id"); appid != c.appid {
+		return nil, fmt.Errorf("app_id mismatch, expect = %s, actual = %s", c.appid, appid)
+	}
+
+	notify := &Notify{
+		NotifyID:   v.Get("notify_id"),
+		NotifyType: v.Get("notify_type"),
+		NotifyTime: v.Get("notify_time"),
+		MsgMethod:  v.Get("msg_method"),
+		Params:     v,
+	}
+
+	if bizContent := v.Get("biz_content"); len(bizContent) != 0 {
+		// 非JSON串，需解密
+		if !strings.HasPrefix(bizContent, "{") {
+			data, err := c.Decrypt(bizContent)
+			if err != nil {
+				return nil, fmt.Errorf("biz_content decrypt error: %w", err)
+			}
+			notify.bizContent = data
+		} else {
+			notify.bizContent = []byte(bizContent)
+		}
+	}
+	return notify, nil
+}
+
+// NotifyHandler 异步通知处理器
+//
+//	fn 返回 nil 时应答 success，否则应答 fail，支付宝将按重试策略再次通知
+//	注意：同一通知可能多次送达，fn 需保证幂等
+func (c *Client) NotifyHandler(fn func(ctx context.Context, n *Notify) error) http.Handler {
+	errLog := internal.ErrLog("notify", c.logger)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		reply := func(s string) {
+			w.Header().Set(internal.HeaderContentType, internal.ContentText)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(s))
+		}
+
+		notify, err := c.ParseNotify(r)
+		if err != nil {
+			errLog(ctx, err)
+			reply(NotifyReplyFail)
+			return
+		}
+		if err = fn(ctx, notify); err != nil {
+			errLog(ctx, err)
+			reply(NotifyReplyFail)
+			return
+		}
+		reply(NotifyReplySuccess)
+	})
+}
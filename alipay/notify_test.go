@@ -0,0 +1,259 @@
+package alipay
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal"
+	"github.com/yiigo/sdk-go/internal/value"
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+const testAppID = "2021000000000000"
+
+// testNotify 模拟支付宝使用私钥签名的异步通知表单
+func testNotify(t *testing.T, key *xcrypto.PrivateKey, signType string, params V) *http.Request {
+	xhash := crypto.SHA256
+	if signType == "RSA" {
+		xhash = crypto.SHA1
+	}
+	sign, err := key.Sign(xhash, []byte(params.Encode("=", "&", value.WithEmptyMode(value.EmptyIgnore))))
+	assert.Nil(t, err)
+
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	form.Set("sign_type", signType)
+	form.Set("sign", base64.StdEncoding.EncodeToString(sign))
+
+	r := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(form.Encode()))
+	r.Header.Set(internal.HeaderContentType, "application/x-www-form-urlencoded")
+	return r
+}
+
+func testTradeParams(extra V) V {
+	v := V{
+		"notify_id":    "ac05099524730693a8b330c5ecf72da9786",
+		"notify_type":  NotifyTradeStatusSync,
+		"notify_time":  "2015-03-20 14:30:00",
+		"app_id":       testAppID,
+		"trade_no":     "2013112011001004330000121536",
+		"out_trade_no": "20150320010101001",
+		"trade_status": TradeSuccess,
+		"total_amount": "88.88",
+		"subject":      "测试 & 商品",
+	}
+	for k, s := range extra {
+		v.Set(k, s)
+	}
+	return v
+}
+
+func TestParseNotify(t *testing.T) {
+	prvKey, pubKey := testRSAKeyPair(t)
+	otherKey, _ := testRSAKeyPair(t)
+
+	c := NewClient(testAppID, testAESKey, WithPublicKey(pubKey))
+
+	bizContent := `{"order_id":"2017112200502000000004754299","external_id":"2088000000000000","merchant_name":"测试商户","smid":"2088000000000001"}`
+	cipher, err := c.Encrypt(bizContent)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		name    string
+		request func() *http.Request
+		check   func(t *testing.T, n *Notify)
+		valid   bool
+	}{
+		{
+			name:    "trade",
+			request: func() *http.Request { return testNotify(t, prvKey, "RSA2", testTradeParams(nil)) },
+			check: func(t *testing.T, n *Notify) {
+				assert.Equal(t, NotifyTradeStatusSync, n.NotifyType)
+				assert.False(t, n.IsRefund())
+				_, err := n.Refund()
+				assert.NotNil(t, err)
+
+				trade, err := n.Trade()
+				assert.Nil(t, err)
+				assert.Equal(t, "20150320010101001", trade.OutTradeNo)
+				assert.Equal(t, "测试 & 商品", trade.Subject)
+				assert.Empty(t, n.Params.Get("sign"))
+				assert.Empty(t, n.Params.Get("sign_type"))
+			},
+			valid: true,
+		},
+		{
+			name:    "sign_type RSA",
+			request: func() *http.Request { return testNotify(t, prvKey, "RSA", testTradeParams(nil)) },
+			valid:   true,
+		},
+		{
+			name: "notify url with query",
+			request: func() *http.Request {
+				r := testNotify(t, prvKey, "RSA2", testTradeParams(nil))
+				r.URL.RawQuery = "tenant_id=1001&total_amount=0.01"
+				return r
+			},
+			check: func(t *testing.T, n *Notify) {
+				assert.Equal(t, "88.88", n.Params.Get("total_amount"))
+				assert.False(t, n.Params.Has("tenant_id"))
+			},
+			valid: true,
+		},
+		{
+			name: "refund with refund_fee",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", testTradeParams(V{"out_biz_no": "R001", "refund_fee": "10.00", "trade_status": TradeClosed}))
+			},
+			check: func(t *testing.T, n *Notify) {
+				assert.True(t, n.IsRefund())
+				refund, err := n.Refund()
+				assert.Nil(t, err)
+				assert.Equal(t, "R001", refund.OutBizNo)
+				assert.Equal(t, "10.00", refund.RefundFee)
+			},
+			valid: true,
+		},
+		{
+			name: "refund with gmt_refund",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", testTradeParams(V{"gmt_refund": "2015-03-20 15:00:00.000"}))
+			},
+			check: func(t *testing.T, n *Notify) {
+				assert.True(t, n.IsRefund())
+			},
+			valid: true,
+		},
+		{
+			name: "not refund for fund auth",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", testTradeParams(V{"notify_type": NotifyFundAuthFreeze, "refund_fee": "1.00"}))
+			},
+			check: func(t *testing.T, n *Notify) {
+				assert.False(t, n.IsRefund())
+				_, err := n.FundAuth()
+				assert.Nil(t, err)
+			},
+			valid: true,
+		},
+		{
+			name: "biz_content encrypted",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", V{"app_id": testAppID, "msg_method": "ant.merchant.expand.indirect.zft.passed", "biz_content": cipher})
+			},
+			check: func(t *testing.T, n *Notify) {
+				assert.Equal(t, bizContent, string(n.BizContent()))
+				m, err := n.Merchant()
+				assert.Nil(t, err)
+				assert.Equal(t, "2088000000000001", m.Smid)
+				assert.Equal(t, "ant.merchant.expand.indirect.zft.passed", m.MsgMethod)
+			},
+			valid: true,
+		},
+		{
+			name: "biz_content plain",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", V{"app_id": testAppID, "msg_method": "ant.merchant.expand.indirect.zft.rejected", "biz_content": bizContent})
+			},
+			check: func(t *testing.T, n *Notify) {
+				assert.Equal(t, bizContent, string(n.BizContent()))
+			},
+			valid: true,
+		},
+		{
+			name: "biz_content decrypt error",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", V{"app_id": testAppID, "biz_content": "invalid"})
+			},
+		},
+		{
+			name: "app_id mismatch",
+			request: func() *http.Request {
+				return testNotify(t, prvKey, "RSA2", testTradeParams(V{"app_id": "2021000000000001"}))
+			},
+		},
+		{
+			name:    "signed by other key",
+			request: func() *http.Request { return testNotify(t, otherKey, "RSA2", testTradeParams(nil)) },
+		},
+		{
+			name: "sign_type mismatch",
+			request: func() *http.Request {
+				r := testNotify(t, prvKey, "RSA", testTradeParams(nil))
+				assert.Nil(t, r.ParseForm())
+				r.PostForm.Set("sign_type", "RSA2")
+				return r
+			},
+		},
+		{
+			name: "tampered",
+			request: func() *http.Request {
+				r := testNotify(t, prvKey, "RSA2", testTradeParams(nil))
+				assert.Nil(t, r.ParseForm())
+				r.PostForm.Set("total_amount", "0.01")
+				return r
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := c.ParseNotify(tc.request())
+			if !tc.valid {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			if tc.check != nil {
+				tc.check(t, n)
+			}
+		})
+	}
+}
+
+func TestNotifyHandler(t *testing.T) {
+	prvKey, pubKey := testRSAKeyPair(t)
+	otherKey, _ := testRSAKeyPair(t)
+
+	c := NewClient(testAppID, testAESKey, WithPublicKey(pubKey))
+
+	cases := []struct {
+		name   string
+		key    *xcrypto.PrivateKey
+		err    error
+		called bool
+		reply  string
+	}{
+		{"success", prvKey, nil, true, NotifyReplySuccess},
+		{"handle error", prvKey, errors.New("db error"), true, NotifyReplyFail},
+		{"verify error", otherKey, nil, false, NotifyReplyFail},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			h := c.NotifyHandler(func(ctx context.Context, n *Notify) error {
+				called = true
+				assert.Equal(t, "20150320010101001", n.Params.Get("out_trade_no"))
+				return tc.err
+			})
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, testNotify(t, tc.key, "RSA2", testTradeParams(nil)))
+			assert.Equal(t, tc.called, called)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.reply, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,480 @@
+package alipay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/tidwall/gjson"
+)
+
+// TradeGoodsDetail 商品明细
+type TradeGoodsDetail struct {
+	GoodsID        string `json:"goods_id"`
+	GoodsName      string `json:"goods_name"`
+	Quantity       int    `json:"quantity"`
+	Price          Amount `json:"price"`
+	GoodsCategory  string `json:"goods_category,omitempty"`
+	CategoriesTree string `json:"categories_tree,omitempty"`
+	ShowURL        string `json:"show_url,omitempty"`
+}
+
+// TradeFundBill 交易支付使用的资金渠道
+type TradeFundBill struct {
+	FundChannel string `json:"fund_channel"`
+	Amount      Amount `json:"amount"`
+	RealAmount  Amount `json:"real_amount"`
+	FundType    string `json:"fund_type"`
+}
+
+// TradeCreateRequest 统一收单交易创建 (alipay.trade.create)
+type TradeCreateRequest struct {
+	OutTradeNo     string              `json:"out_trade_no"`
+	TotalAmount    Amount              `json:"total_amount"`
+	Subject        string              `json:"subject"`
+	Body           string              `json:"body,omitempty"`
+	ProductCode    string              `json:"product_code,omitempty"`
+	SellerID       string              `json:"seller_id,omitempty"`
+	BuyerID        string              `json:"buyer_id,omitempty"`
+	BuyerOpenID    string              `json:"buyer_open_id,omitempty"`
+	OpAppID        string              `json:"op_app_id,omitempty"`
+	TimeoutExpress string              `json:"timeout_express,omitempty"` // 相对超时时间，如：90m
+	TimeExpire     *Time               `json:"time_expire,omitempty"`     // 绝对超时时间 (北京时间)
+	StoreID        string              `json:"store_id,omitempty"`
+	OperatorID     string              `json:"operator_id,omitempty"`
+	TerminalID     string              `json:"terminal_id,omitempty"`
+	GoodsDetail    []*TradeGoodsDetail `json:"goods_detail,omitempty"`
+	NotifyURL      string              `json:"notify_url,omitempty"`
+}
+
+// TradeCreateResponse 统一收单交易创建结果
+type TradeCreateResponse struct {
+	OutTradeNo string `json:"out_trade_no"`
+	TradeNo    string `json:"trade_no"`
+}
+
+// TradePayRequest 统一收单交易支付 (alipay.trade.pay，如：付款码支付)
+type TradePayRequest struct {
+	OutTradeNo     string              `json:"out_trade_no"`
+	TotalAmount    Amount              `json:"total_amount"`
+	Subject        string              `json:"subject"`
+	AuthCode       string              `json:"auth_code"`
+	Scene          string              `json:"scene"`
+	ProductCode    string              `json:"product_code,omitempty"`
+	SellerID       string              `json:"seller_id,omitempty"`
+	TimeoutExpress string              `json:"timeout_express,omitempty"`
+	StoreID        string              `json:"store_id,omitempty"`
+	OperatorID     string              `json:"operator_id,omitempty"`
+	TerminalID     string              `json:"terminal_id,omitempty"`
+	GoodsDetail    []*TradeGoodsDetail `json:"goods_detail,omitempty"`
+	NotifyURL      string              `json:"notify_url,omitempty"`
+}
+
+// TradePayResponse 统一收单交易支付结果
+type TradePayResponse struct {
+	TradeNo        string           `json:"trade_no"`
+	OutTradeNo     string           `json:"out_trade_no"`
+	BuyerLogonID   string           `json:"buyer_logon_id"`
+	BuyerUserID    string           `json:"buyer_user_id"`
+	BuyerOpenID    string           `json:"buyer_open_id"`
+	TotalAmount    Amount           `json:"total_amount"`
+	ReceiptAmount  Amount           `json:"receipt_amount"`
+	BuyerPayAmount Amount           `json:"buyer_pay_amount"`
+	PointAmount    Amount           `json:"point_amount"`
+	InvoiceAmount  Amount           `json:"invoice_amount"`
+	GmtPayment     Time             `json:"gmt_payment"`
+	FundBillList   []*TradeFundBill `json:"fund_bill_list"`
+}
+
+// TradePrecreateRequest 统一收单线下交易预创建 (alipay.trade.precreate，扫码支付)
+type TradePrecreateRequest struct {
+	OutTradeNo     string              `json:"out_trade_no"`
+	TotalAmount    Amount              `json:"total_amount"`
+	Subject        string              `json:"subject"`
+	ProductCode    string              `json:"product_code,omitempty"`
+	SellerID       string              `json:"seller_id,omitempty"`
+	TimeoutExpress string              `json:"timeout_express,omitempty"`
+	StoreID        string              `json:"store_id,omitempty"`
+	OperatorID     string              `json:"operator_id,omitempty"`
+	TerminalID     string              `json:"terminal_id,omitempty"`
+	GoodsDetail    []*TradeGoodsDetail `json:"goods_detail,omitempty"`
+	NotifyURL      string              `json:"notify_url,omitempty"`
+}
+
+// TradePrecreateResponse 统一收单线下交易预创建结果
+type TradePrecreateResponse struct {
+	OutTradeNo string `json:"out_trade_no"`
+	QRCode     string `json:"qr_code"`
+}
+
+// TradeQueryRequest 统一收单交易查询 (alipay.trade.query)
+type TradeQueryRequest struct {
+	OutTradeNo   string   `json:"out_trade_no,omitempty"`
+	TradeNo      string   `json:"trade_no,omitempty"`
+	QueryOptions []string `json:"query_options,omitempty"`
+}
+
+// TradeQueryResponse 统一收单交易查询结果
+type TradeQueryResponse struct {
+	TradeNo        string           `json:"trade_no"`
+	OutTradeNo     string           `json:"out_trade_no"`
+	BuyerLogonID   string           `json:"buyer_logon_id"`
+	BuyerUserID    string           `json:"buyer_user_id"`
+	BuyerOpenID    string           `json:"buyer_open_id"`
+	TradeStatus    string           `json:"trade_status"`
+	TotalAmount    Amount           `json:"total_amount"`
+	ReceiptAmount  Amount           `json:"receipt_amount"`
+	BuyerPayAmount Amount           `json:"buyer_pay_amount"`
+	PointAmount    Amount           `json:"point_amount"`
+	InvoiceAmount  Amount           `json:"invoice_amount"`
+	SendPayDate    Time             `json:"send_pay_date"`
+	StoreID        string           `json:"store_id"`
+	TerminalID     string           `json:"terminal_id"`
+	FundBillList   []*TradeFundBill `json:"fund_bill_list"`
+}
+
+// TradeRefundRequest 统一收单交易退款 (alipay.trade.refund)
+type TradeRefundRequest struct {
+	OutTradeNo   string `json:"out_trade_no,omitempty"`
+	TradeNo      string `json:"trade_no,omitempty"`
+	RefundAmount Amount `json:"refund_amount"`
+	RefundReason string `json:"refund_reason,omitempty"`
+	OutRequestNo string `json:"out_request_no,omitempty"` // 部分退款必传
+	OperatorID   string `json:"operator_id,omitempty"`
+	StoreID      string `json:"store_id,omitempty"`
+	TerminalID   string `json:"terminal_id,omitempty"`
+}
+
+// TradeRefundResponse 统一收单交易退款结果
+type TradeRefundResponse struct {
+	TradeNo      string `json:"trade_no"`
+	OutTradeNo   string `json:"out_trade_no"`
+	BuyerLogonID string `json:"buyer_logon_id"`
+	BuyerUserID  string `json:"buyer_user_id"`
+	BuyerOpenID  string `json:"buyer_open_id"`
+	FundChange   string `json:"fund_change"` // 本次退款是否发生了资金变化 (Y/N)
+	RefundFee    Amount `json:"refund_fee"`  // 退款总金额
+	GmtRefundPay Time   `json:"gmt_refund_pay"`
+}
+
+// TradeRefundQueryRequest 统一收单交易退款查询 (alipay.trade.fastpay.refund.query)
+type TradeRefundQueryRequest struct {
+	OutTradeNo   string   `json:"out_trade_no,omitempty"`
+	TradeNo      string   `json:"trade_no,omitempty"`
+	OutRequestNo string   `json:"out_request_no"`
+	QueryOptions []string `json:"query_options,omitempty"`
+}
+
+// TradeRefundQueryResponse 统一收单交易退款查询结果
+type TradeRefundQueryResponse struct {
+	TradeNo      string `json:"trade_no"`
+	OutTradeNo   string `json:"out_trade_no"`
+	OutRequestNo string `json:"out_request_no"`
+	TotalAmount  Amount `json:"total_amount"`
+	RefundAmount Amount `json:"refund_amount"`
+	RefundStatus string `json:"refund_status"` // REFUND_SUCCESS 退款处理成功
+	GmtRefundPay Time   `json:"gmt_refund_pay"`
+}
+
+// TradeCloseRequest 统一收单交易关闭 (alipay.trade.close)
+type TradeCloseRequest struct {
+	OutTradeNo string `json:"out_trade_no,omitempty"`
+	TradeNo    string `json:"trade_no,omitempty"`
+	OperatorID string `json:"operator_id,omitempty"`
+}
+
+// TradeCloseResponse 统一收单交易关闭结果
+type TradeCloseResponse struct {
+	TradeNo    string `json:"trade_no"`
+	OutTradeNo string `json:"out_trade_no"`
+}
+
+// TradeCancelRequest 统一收单交易撤销 (alipay.trade.cancel)
+type TradeCancelRequest struct {
+	OutTradeNo string `json:"out_trade_no,omitempty"`
+	TradeNo    string `json:"trade_no,omitempty"`
+}
+
+// TradeCancelResponse 统一收单交易撤销结果
+type TradeCancelResponse struct {
+	TradeNo    string `json:"trade_no"`
+	OutTradeNo string `json:"out_trade_no"`
+	RetryFlag  string `json:"retry_flag"` // 是否需要重试 (Y/N)
+	Action     string `json:"action"`     // 本次撤销触发的交易动作 (close/refund)
+}
+
+// BillDownloadURLQueryRequest 查询对账单下载地址 (alipay.data.dataservice.bill.downloadurl.query)
+type BillDownloadURLQueryRequest struct {
+	BillType string `json:"bill_type"` // trade/signcustomer
+	BillDate string `json:"bill_date"` // 日账单：yyyy-MM-dd，月账单：yyyy-MM
+}
+
+// BillDownloadURLQueryResponse 查询对账单下载地址结果
+type BillDownloadURLQueryResponse struct {
+	BillDownloadURL string `json:"bill_download_url"`
+}
+
+// --------------------------- Client ---------------------------
+
+// TradeCreate 统一收单交易创建
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfj)
+func (c *Client) TradeCreate(ctx context.Context, req *TradeCreateRequest, options ...ActionOption) (*TradeCreateResponse, error) {
+	ret := new(TradeCreateResponse)
+	if err := c.doTyped(ctx, "alipay.trade.create", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradePay 统一收单交易支付
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfp)
+func (c *Client) TradePay(ctx context.Context, req *TradePayRequest, options ...ActionOption) (*TradePayResponse, error) {
+	ret := new(TradePayResponse)
+	if err := c.doTyped(ctx, "alipay.trade.pay", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradePrecreate 统一收单线下交易预创建
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfg)
+func (c *Client) TradePrecreate(ctx context.Context, req *TradePrecreateRequest, options ...ActionOption) (*TradePrecreateResponse, error) {
+	ret := new(TradePrecreateResponse)
+	if err := c.doTyped(ctx, "alipay.trade.precreate", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeQuery 统一收单交易查询
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfh)
+func (c *Client) TradeQuery(ctx context.Context, req *TradeQueryRequest, options ...ActionOption) (*TradeQueryResponse, error) {
+	ret := new(TradeQueryResponse)
+	if err := c.doTyped(ctx, "alipay.trade.query", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeRefund 统一收单交易退款
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfk)
+func (c *Client) TradeRefund(ctx context.Context, req *TradeRefundRequest, options ...ActionOption) (*TradeRefundResponse, error) {
+	ret := new(TradeRefundResponse)
+	if err := c.doTyped(ctx, "alipay.trade.refund", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeRefundQuery 统一收单交易退款查询
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfl)
+func (c *Client) TradeRefundQuery(ctx context.Context, req *TradeRefundQueryRequest, options ...ActionOption) (*TradeRefundQueryResponse, error) {
+	ret := new(TradeRefundQueryResponse)
+	if err := c.doTyped(ctx, "alipay.trade.fastpay.refund.query", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeClose 统一收单交易关闭
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfi)
+func (c *Client) TradeClose(ctx context.Context, req *TradeCloseRequest, options ...ActionOption) (*TradeCloseResponse, error) {
+	ret := new(TradeCloseResponse)
+	if err := c.doTyped(ctx, "alipay.trade.close", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeCancel 统一收单交易撤销
+//
+//	[参考](https://opendocs.alipay.com/open/02ekfd)
+func (c *Client) TradeCancel(ctx context.Context, req *TradeCancelRequest, options ...ActionOption) (*TradeCancelResponse, error) {
+	ret := new(TradeCancelResponse)
+	if err := c.doTyped(ctx, "alipay.trade.cancel", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// BillDownloadURLQuery 查询对账单下载地址
+//
+//	[参考](https://opendocs.alipay.com/open/02e7gr)
+func (c *Client) BillDownloadURLQuery(ctx context.Context, req *BillDownloadURLQueryRequest, options ...ActionOption) (*BillDownloadURLQueryResponse, error) {
+	ret := new(BillDownloadURLQueryResponse)
+	if err := c.doTyped(ctx, "alipay.data.dataservice.bill.downloadurl.query", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// doTyped 发送请求，req 作为「biz_content」，结果解析到 resp
+func (c *Client) doTyped(ctx context.Context, method string, req, resp any, options ...ActionOption) error {
+	bizData, err := toX(req)
+	if err != nil {
+		return err
+	}
+	// notify_url 为公共请求参数
+	if v, ok := bizData["notify_url"].(string); ok {
+		delete(bizData, "notify_url")
+		options = append([]ActionOption{WithNotifyURL(v)}, options...)
+	}
+	options = append([]ActionOption{WithBizContent(bizData)}, options...)
+
+	ret, err := c.Do(ctx, method, options...)
+	if err != nil {
+		return err
+	}
+	return decodeResult(ret, resp)
+}
+
+// --------------------------- ClientV3 ---------------------------
+
+// TradeCreate 统一收单交易创建
+//
+//	[参考](https://opendocs.alipay.com/open-v3/05ffbb6e_alipay.trade.create)
+func (c *ClientV3) TradeCreate(ctx context.Context, req *TradeCreateRequest, options ...V3HeaderOption) (*TradeCreateResponse, error) {
+	ret := new(TradeCreateResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/create", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradePay 统一收单交易支付
+//
+//	[参考](https://opendocs.alipay.com/open-v3/08c7f9f8_alipay.trade.pay)
+func (c *ClientV3) TradePay(ctx context.Context, req *TradePayRequest, options ...V3HeaderOption) (*TradePayResponse, error) {
+	ret := new(TradePayResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/pay", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradePrecreate 统一收单线下交易预创建
+//
+//	[参考](https://opendocs.alipay.com/open-v3/8e8e7cc8_alipay.trade.precreate)
+func (c *ClientV3) TradePrecreate(ctx context.Context, req *TradePrecreateRequest, options ...V3HeaderOption) (*TradePrecreateResponse, error) {
+	ret := new(TradePrecreateResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/precreate", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeQuery 统一收单交易查询
+//
+//	[参考](https://opendocs.alipay.com/open-v3/bff76748_alipay.trade.query)
+func (c *ClientV3) TradeQuery(ctx context.Context, req *TradeQueryRequest, options ...V3HeaderOption) (*TradeQueryResponse, error) {
+	ret := new(TradeQueryResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/query", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeRefund 统一收单交易退款
+//
+//	[参考](https://opendocs.alipay.com/open-v3/4b3bb8a0_alipay.trade.refund)
+func (c *ClientV3) TradeRefund(ctx context.Context, req *TradeRefundRequest, options ...V3HeaderOption) (*TradeRefundResponse, error) {
+	ret := new(TradeRefundResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/refund", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeRefundQuery 统一收单交易退款查询
+//
+//	[参考](https://opendocs.alipay.com/open-v3/357441a2_alipay.trade.fastpay.refund.query)
+func (c *ClientV3) TradeRefundQuery(ctx context.Context, req *TradeRefundQueryRequest, options ...V3HeaderOption) (*TradeRefundQueryResponse, error) {
+	ret := new(TradeRefundQueryResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/fastpay/refund/query", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeClose 统一收单交易关闭
+//
+//	[参考](https://opendocs.alipay.com/open-v3/e84f0d79_alipay.trade.close)
+func (c *ClientV3) TradeClose(ctx context.Context, req *TradeCloseRequest, options ...V3HeaderOption) (*TradeCloseResponse, error) {
+	ret := new(TradeCloseResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/close", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeCancel 统一收单交易撤销
+//
+//	[参考](https://opendocs.alipay.com/open-v3/13399a6b_alipay.trade.cancel)
+func (c *ClientV3) TradeCancel(ctx context.Context, req *TradeCancelRequest, options ...V3HeaderOption) (*TradeCancelResponse, error) {
+	ret := new(TradeCancelResponse)
+	if err := c.postTyped(ctx, "/v3/alipay/trade/cancel", req, ret, options...); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// BillDownloadURLQuery 查询对账单下载地址
+//
+//	[参考](https://opendocs.alipay.com/open-v3/e4fd3b0c_alipay.data.dataservice.bill.downloadurl.query)
+func (c *ClientV3) BillDownloadURLQuery(ctx context.Context, req *BillDownloadURLQueryRequest, options ...V3HeaderOption) (*BillDownloadURLQueryResponse, error) {
+	query := url.Values{}
+	query.Set("bill_type", req.BillType)
+	query.Set("bill_date", req.BillDate)
+
+	ret, err := c.GetJSON(ctx, "/v3/alipay/data/dataservice/bill/downloadurl/query", query, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(BillDownloadURLQueryResponse)
+	if err = decodeResult(ret.Body, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// postTyped POST请求，结果解析到 resp
+func (c *ClientV3) postTyped(ctx context.Context, path string, req, resp any, options ...V3HeaderOption) error {
+	params, err := toX(req)
+	if err != nil {
+		return err
+	}
+
+	ret, err := c.PostJSON(ctx, path, params, options...)
+	if err != nil {
+		return err
+	}
+	return decodeResult(ret.Body, resp)
+}
+
+func toX(v any) (X, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	x := make(X)
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err = decoder.Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func decodeResult(ret gjson.Result, v any) error {
+	return json.Unmarshal([]byte(ret.Raw), v)
+}
@@ -0,0 +1,114 @@
+package alipay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Amount 金额 (单位：分)，JSON 序列化为「元」，如：1234 <-> "12.34"
+type Amount int64
+
+func (a Amount) String() string {
+	v := int64(a)
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + a.String() + `"`), nil
+}
+
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if len(s) == 0 || s == "null" {
+		*a = 0
+		return nil
+	}
+
+	v, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// ParseAmount 解析「元」为金额，如："12.34" -> 1234
+func ParseAmount(s string) (Amount, error) {
+	str := strings.TrimSpace(s)
+
+	neg := strings.HasPrefix(str, "-")
+	str = strings.TrimPrefix(str, "-")
+
+	yuan, fen, _ := strings.Cut(str, ".")
+	if len(yuan) == 0 && len(fen) == 0 {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	// 超过2位的小数须为0
+	if len(fen) > 2 {
+		if strings.Trim(fen[2:], "0") != "" {
+			return 0, fmt.Errorf("invalid amount: %s", s)
+		}
+		fen = fen[:2]
+	}
+	fen += strings.Repeat("0", 2-len(fen))
+
+	if len(yuan) == 0 {
+		yuan = "0"
+	}
+	y, err := strconv.ParseUint(yuan, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+	f, err := strconv.ParseUint(fen, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", s)
+	}
+
+	v := int64(y*100 + f)
+	if neg {
+		v = -v
+	}
+	return Amount(v), nil
+}
+
+// TimeLayout 支付宝时间格式
+const TimeLayout = "2006-01-02 15:04:05"
+
+// 支付宝时间均为北京时间
+var cst = time.FixedZone("CST", 8*3600)
+
+// Time 时间 (格式：yyyy-MM-dd HH:mm:ss)
+type Time struct {
+	time.Time
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(t.In(cst).Format(TimeLayout))
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if len(s) == 0 || s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	v, err := time.ParseInLocation(TimeLayout, s, cst)
+	if err != nil {
+		return err
+	}
+	t.Time = v
+	return nil
+}
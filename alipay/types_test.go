@@ -0,0 +1,114 @@
+package alipay
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := []struct {
+		s      string
+		amount Amount
+		valid  bool
+	}{
+		{"0.1", 10, true},
+		{"1.00", 100, true},
+		{"1", 100, true},
+		{".5", 50, true},
+		{"12.34", 1234, true},
+		{"1.010", 101, true},
+		{"1.001", 0, false},
+		{"-0.01", -1, true},
+		{"-12.3", -1230, true},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"1.a", 0, false},
+		{"--1", 0, false},
+		{".", 0, false},
+	}
+
+	for _, c := range cases {
+		v, err := ParseAmount(c.s)
+		if !c.valid {
+			assert.NotNil(t, err, c.s)
+			continue
+		}
+		assert.Nil(t, err, c.s)
+		assert.Equal(t, c.amount, v, c.s)
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	assert.Equal(t, "0.10", Amount(10).String())
+	assert.Equal(t, "-0.05", Amount(-5).String())
+
+	var v struct {
+		Total  Amount `json:"total_amount"`
+		Refund Amount `json:"refund_fee"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(`{"total_amount":"88.88","refund_fee":null}`), &v))
+	assert.Equal(t, Amount(8888), v.Total)
+	assert.Equal(t, Amount(0), v.Refund)
+
+	b, err := json.Marshal(v)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"total_amount":"88.88","refund_fee":"0.00"}`, string(b))
+
+	assert.NotNil(t, json.Unmarshal([]byte(`{"total_amount":"1.001"}`), &v))
+}
+
+func TestTimeJSON(t *testing.T) {
+	var v struct {
+		GmtPayment Time `json:"gmt_payment"`
+	}
+
+	// 按北京时间解析
+	assert.Nil(t, json.Unmarshal([]byte(`{"gmt_payment":"2014-11-27 15:45:57"}`), &v))
+	assert.Equal(t, time.Date(2014, 11, 27, 7, 45, 57, 0, time.UTC).Unix(), v.GmtPayment.Unix())
+
+	// 按北京时间输出
+	v.GmtPayment = Time{time.Date(2014, 11, 27, 7, 45, 57, 0, time.UTC)}
+	b, err := json.Marshal(v)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"gmt_payment":"2014-11-27 15:45:57"}`, string(b))
+
+	v.GmtPayment = Time{}
+	b, err = json.Marshal(v)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"gmt_payment":""}`, string(b))
+}
+
+func TestToX(t *testing.T) {
+	x, err := toX(struct {
+		OutTradeNo  string `json:"out_trade_no"`
+		TotalAmount Amount `json:"total_amount"`
+		Quantity    int64  `json:"quantity"`
+	}{"20150320010101001", 1234, 9007199254740993})
+	assert.Nil(t, err)
+
+	// 整数不转为 float64
+	assert.Equal(t, json.Number("9007199254740993"), x["quantity"])
+	assert.Equal(t, "12.34", x["total_amount"])
+
+	b, err := json.Marshal(x)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"out_trade_no":"20150320010101001","total_amount":"12.34","quantity":9007199254740993}`, string(b))
+}
+
+func TestTradeCreateTimeExpire(t *testing.T) {
+	req := &TradeCreateRequest{OutTradeNo: "20150320010101001", TotalAmount: 8888, Subject: "Iphone6 16G"}
+
+	x, err := toX(req)
+	assert.Nil(t, err)
+	_, ok := x["time_expire"]
+	assert.False(t, ok)
+
+	// 按北京时间输出
+	req.TimeExpire = &Time{time.Date(2016, 12, 31, 2, 0, 0, 0, time.UTC)}
+	x, err = toX(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "2016-12-31 10:00:00", x["time_expire"])
+}
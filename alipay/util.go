@@ -1,9 +1,14 @@
 package alipay
 
 import (
+	"context"
+	"net/http"
 	"strings"
 
+	"github.com/go-resty/resty/v2"
 	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal"
 	"github.com/yiigo/sdk-go/internal/value"
 	"github.com/yiigo/sdk-go/internal/xcrypto"
 )
@@ -12,6 +17,9 @@ type V = value.V
 
 type X map[string]any
 
+// FormPart 文件上传的 multipart 表单项 (Reader 实现 io.Seeker 时支持失败重试)
+type FormPart = internal.FormPart
+
 const CodeOK = "10000" // API请求成功
 
 const (
@@ -24,6 +32,7 @@ const (
 	HeaderEncryptType    = "alipay-encrypt-type"
 	HeaderAppAuthToken   = "alipay-app-auth-token"
 	HeaderSignature      = "alipay-signature"
+	HeaderSN             = "alipay-sn"
 )
 
 type GrantType string
@@ -33,7 +42,7 @@ const (
 	RefreshToken GrantType = "refresh_token"
 )
 
-// APIResult API结果 (支付v3)
+// APIResult API结果 (支付v3，HTTP状态码 >= 400 时返回 *Error)
 type APIResult struct {
 	Code int // HTTP状态码
 	Body gjson.Result
@@ -173,3 +182,32 @@ func FormatPKCS8PublicKey(pemStr string) (xcrypto.RSAPadding, []byte) {
 
 	return xcrypto.RSA_PKCS8, []byte(builder.String())
 }
+
+// postMultipart 流式发送 multipart 表单
+//
+//	网络错误时，若表单可重复读取则重试 (每次重试重新生成请求头，如：签名)
+func postMultipart(ctx context.Context, cli *resty.Client, reqURL string, form *internal.MultipartForm, retry int, header func() (http.Header, error)) (*resty.Response, error) {
+	for i := 0; ; i++ {
+		h, err := header()
+		if err != nil {
+			return nil, err
+		}
+
+		body, contentType, err := form.Reader()
+		if err != nil {
+			return nil, err
+		}
+		h.Set(internal.HeaderContentType, contentType)
+
+		resp, err := cli.R().
+			SetContext(ctx).
+			SetHeaderMultiValues(h).
+			SetBody(body).
+			Post(reqURL)
+		// 请求失败时写入端可能阻塞，关闭以释放
+		body.Close()
+		if err == nil || i >= retry || ctx.Err() != nil || !form.Rewindable() {
+			return resp, err
+		}
+	}
+}
@@ -79,3 +79,13 @@ func HeaderEncode(h http.Header) string {
 	}
 	return buf.String()
 }
+
+// ErrLog 生成异步任务(如：定时刷新、回调通知)的错误上报函数
+func ErrLog(action string, log func(ctx context.Context, err error, data map[string]string)) func(ctx context.Context, err error) {
+	return func(ctx context.Context, err error) {
+		if log == nil {
+			return
+		}
+		log(ctx, err, map[string]string{"action": action})
+	}
+}
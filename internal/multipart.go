@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// FormPart multipart 表单项
+type FormPart struct {
+	FieldName   string    // 字段名
+	FileName    string    // 文件名 (为空表示普通字段)
+	ContentType string    // 内容类型 (文件默认：application/octet-stream)
+	Reader      io.Reader // 内容 (实现 io.Seeker 时，请求重试可重新读取)
+}
+
+// MultipartForm 流式 multipart 表单
+//
+//	通过 io.Pipe 边读边写，文件内容无需整体载入内存
+type MultipartForm struct {
+	parts   []*FormPart
+	offsets []int64 // 各表单项添加时的读取位置 (-1 表示不可重置)
+	used    bool
+}
+
+// NewMultipartForm 生成流式 multipart 表单
+func NewMultipartForm(parts ...*FormPart) *MultipartForm {
+	f := new(MultipartForm)
+	for _, p := range parts {
+		f.Part(p)
+	}
+	return f
+}
+
+// Field 添加普通字段
+func (f *MultipartForm) Field(name, value string) *MultipartForm {
+	return f.Part(&FormPart{FieldName: name, Reader: strings.NewReader(value)})
+}
+
+// Part 添加表单项 (实现 io.Seeker 时记录当前读取位置，重试时从该位置重新读取)
+func (f *MultipartForm) Part(p *FormPart) *MultipartForm {
+	offset := int64(-1)
+	if s, ok := p.Reader.(io.Seeker); ok {
+		if n, err := s.Seek(0, io.SeekCurrent); err == nil {
+			offset = n
+		}
+	}
+
+	f.parts = append(f.parts, p)
+	f.offsets = append(f.offsets, offset)
+	return f
+}
+
+// Rewindable 表单是否可重复读取 (所有表单项均实现 io.Seeker)
+func (f *MultipartForm) Rewindable() bool {
+	for _, offset := range f.offsets {
+		if offset < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reader 返回表单Body及其 Content-Type
+//
+//	再次调用时，会将各表单项重置到添加时的位置；若存在不可重置的表单项则返回错误
+func (f *MultipartForm) Reader() (io.ReadCloser, string, error) {
+	if f.used {
+		for i, p := range f.parts {
+			if f.offsets[i] < 0 {
+				return nil, "", fmt.Errorf("multipart field(%s) is not rewindable", p.FieldName)
+			}
+			if _, err := p.Reader.(io.Seeker).Seek(f.offsets[i], io.SeekStart); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	f.used = true
+
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(f.write(w))
+	}()
+	return pr, w.FormDataContentType(), nil
+}
+
+func (f *MultipartForm) write(w *multipart.Writer) error {
+	for _, p := range f.parts {
+		if p.Reader == nil {
+			return fmt.Errorf("multipart field(%s) reader is nil", p.FieldName)
+		}
+
+		h := make(textproto.MIMEHeader)
+
+		disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.FieldName))
+		if len(p.FileName) != 0 {
+			disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(p.FileName))
+		}
+		h.Set("Content-Disposition", disposition)
+
+		contentType := p.ContentType
+		if len(contentType) == 0 && len(p.FileName) != 0 {
+			contentType = ContentStream
+		}
+		if len(contentType) != 0 {
+			h.Set(HeaderContentType, contentType)
+		}
+
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(pw, p.Reader); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
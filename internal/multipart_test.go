@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readMultipart(t *testing.T, form *MultipartForm) map[string][2]string {
+	body, contentType, err := form.Reader()
+	assert.Nil(t, err)
+	defer body.Close()
+
+	_, params, err := mime.ParseMediaType(contentType)
+	assert.Nil(t, err)
+
+	ret := make(map[string][2]string)
+
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+
+		b, err := io.ReadAll(p)
+		assert.Nil(t, err)
+		ret[p.FormName()] = [2]string{p.Header.Get(HeaderContentType), string(b)}
+	}
+	return ret
+}
+
+func TestMultipartForm(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1<<16)
+
+	form := NewMultipartForm(&FormPart{FieldName: "image_content", FileName: "a.jpg", Reader: bytes.NewReader(content)})
+	form.Field("scene", "SYNC_ORDER")
+	form.Part(&FormPart{FieldName: "data", ContentType: ContentJSON, Reader: strings.NewReader(`{"a":1}`)})
+	assert.True(t, form.Rewindable())
+
+	// 重试时可重复读取
+	for i := 0; i < 2; i++ {
+		ret := readMultipart(t, form)
+		assert.Equal(t, [2]string{ContentStream, string(content)}, ret["image_content"])
+		assert.Equal(t, [2]string{"", "SYNC_ORDER"}, ret["scene"])
+		assert.Equal(t, [2]string{ContentJSON, `{"a":1}`}, ret["data"])
+	}
+
+	// 从表单项添加时的位置重新读取
+	r := bytes.NewReader(content)
+	_, err := r.Seek(10, io.SeekStart)
+	assert.Nil(t, err)
+	form = NewMultipartForm(&FormPart{FieldName: "file", FileName: "a.bin", Reader: r})
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, [2]string{ContentStream, string(content[10:])}, readMultipart(t, form)["file"])
+	}
+
+	// 不可重置的表单项
+	form = NewMultipartForm(&FormPart{FieldName: "file", FileName: "b.png", ContentType: "image/png", Reader: io.LimitReader(bytes.NewReader(content), 10)})
+	assert.False(t, form.Rewindable())
+	assert.Equal(t, [2]string{"image/png", "0123456789"}, readMultipart(t, form)["file"])
+
+	_, _, err = form.Reader()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"context"
+	"time"
+)
+
+// Refresh 按指定间隔执行fn，直至ctx取消
+//
+//	执行失败时，通过onErr上报错误，并按指数退避(1s起，不超过interval)重试
+func Refresh(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error, onErr func(ctx context.Context, err error)) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	var backoff time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if err := fn(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if onErr != nil {
+				onErr(ctx, err)
+			}
+
+			if backoff == 0 {
+				backoff = time.Second
+			} else {
+				backoff *= 2
+			}
+			if backoff > interval {
+				backoff = interval
+			}
+			timer.Reset(backoff)
+			continue
+		}
+
+		backoff = 0
+		timer.Reset(interval)
+	}
+}
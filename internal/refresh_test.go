@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefresh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		calls  int32
+		errCnt int32
+	)
+
+	done := make(chan struct{})
+	go func() {
+		Refresh(ctx, 10*time.Millisecond, func(ctx context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return errors.New("oops")
+			}
+			return nil
+		}, func(ctx context.Context, err error) {
+			atomic.AddInt32(&errCnt, 1)
+		})
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresh not stopped after cancel")
+	}
+
+	assert.True(t, atomic.LoadInt32(&calls) >= 2)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&errCnt))
+}
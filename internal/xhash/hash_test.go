@@ -67,3 +67,8 @@ func TestHash(t *testing.T) {
 		assert.Equal(t, tt.want, v)
 	}
 }
+
+func TestSM3(t *testing.T) {
+	assert.Equal(t, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0", SM3("abc"))
+	assert.Equal(t, "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732", SM3("abcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcdabcd"))
+}
@@ -0,0 +1,515 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal"
+)
+
+const (
+	verifyTicketTTL = 12 * time.Hour             // component_verify_ticket 有效期 (每10分钟推送一次，有效期12小时)
+	refreshTokenTTL = 100 * 365 * 24 * time.Hour // authorizer_refresh_token 长期有效 (取消授权后失效)
+)
+
+// errAuthorizerSecret 代公众号/小程序调用时无appsecret，access_token 由第三方平台获取
+var errAuthorizerSecret = errors.New("authorizer has no secret, access_token is obtained via component")
+
+// Component 微信开放平台第三方平台
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/getting_started/terminology_introduce.html)
+type Component struct {
+	host   string
+	appid  string
+	secret string
+	srvCfg *ServerConfig
+	store  TokenStore   // component_verify_ticket 及各类凭据的存储
+	token  *tokenSource // component_access_token
+	client *resty.Client
+	logger func(ctx context.Context, err error, data map[string]string)
+}
+
+// AppID 返回第三方平台appid
+func (c *Component) AppID() string {
+	return c.appid
+}
+
+// Secret 返回第三方平台appsecret
+func (c *Component) Secret() string {
+	return c.secret
+}
+
+func (c *Component) url(path string, query url.Values) string {
+	var builder strings.Builder
+
+	builder.WriteString(c.host)
+	if len(path) != 0 && path[0] != '/' {
+		builder.WriteString("/")
+	}
+	builder.WriteString(path)
+	if len(query) != 0 {
+		builder.WriteString("?")
+		builder.WriteString(query.Encode())
+	}
+
+	return builder.String()
+}
+
+func (c *Component) do(ctx context.Context, method, path string, query url.Values, params X) ([]byte, error) {
+	reqURL := c.url(path, query)
+
+	log := internal.NewReqLog(method, reqURL)
+	defer log.Do(ctx, c.logger)
+
+	req := c.client.R().SetContext(ctx)
+	if params != nil {
+		body, err := json.Marshal(params)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetReqBody(string(body))
+
+		req.SetHeader(internal.HeaderContentType, internal.ContentJSON).SetBody(body)
+	}
+
+	resp, err := req.Execute(method, reqURL)
+	if err != nil {
+		log.SetError(err)
+		return nil, err
+	}
+	log.SetRespHeader(resp.Header())
+	log.SetStatusCode(resp.StatusCode())
+	log.SetRespBody(string(resp.Body()))
+	if !resp.IsSuccess() {
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
+	}
+	return resp.Body(), nil
+}
+
+func (c *Component) ticketKey() string {
+	return "wechat:component:" + c.appid + ":verify_ticket"
+}
+
+func (c *Component) refreshTokenKey(appid string) string {
+	return "wechat:component:" + c.appid + ":" + appid + ":refresh_token"
+}
+
+func (c *Component) authorizerTokenKey(appid string) string {
+	return "wechat:component:" + c.appid + ":" + appid + ":access_token"
+}
+
+// SetVerifyTicket 保存推送的 component_verify_ticket (使用 EventServer 时自动保存)
+func (c *Component) SetVerifyTicket(ctx context.Context, ticket string) error {
+	return c.store.Set(ctx, c.ticketKey(), &Token{
+		Value:    ticket,
+		ExpireAt: time.Now().Add(verifyTicketTTL),
+	})
+}
+
+// VerifyTicket 返回最近一次推送的 component_verify_ticket
+func (c *Component) VerifyTicket(ctx context.Context) (string, error) {
+	t, err := c.store.Get(ctx, c.ticketKey())
+	if err != nil {
+		return "", err
+	}
+	if t == nil || len(t.Value) == 0 {
+		return "", errors.New("component_verify_ticket is empty (callback not received?)")
+	}
+	return t.Value, nil
+}
+
+// ComponentAccessToken 获取第三方平台接口调用凭据
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/openApi/OpenApiDoc/ticket-token/getComponentAccessToken.html)
+func (c *Component) ComponentAccessToken(ctx context.Context) (gjson.Result, error) {
+	ticket, err := c.VerifyTicket(ctx)
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	b, err := c.do(ctx, http.MethodPost, "/cgi-bin/component/api_component_token", nil, X{
+		"component_appid":         c.appid,
+		"component_appsecret":     c.secret,
+		"component_verify_ticket": ticket,
+	})
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
+	}
+	return ret, nil
+}
+
+// PostJSON 使用 component_access_token 发起POST请求
+func (c *Component) PostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
+	query := url.Values{}
+
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set("component_access_token", token)
+		return c.do(ctx, http.MethodPost, path, query, params)
+	})
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
+	}
+	return ret, nil
+}
+
+// GetJSON 使用 component_access_token 发起GET请求
+func (c *Component) GetJSON(ctx context.Context, path string, query url.Values) (gjson.Result, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set("component_access_token", token)
+		return c.do(ctx, http.MethodGet, path, query, nil)
+	})
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
+	}
+	return ret, nil
+}
+
+// PreAuthCode 获取预授权码
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/openApi/OpenApiDoc/ticket-token/getPreAuthCode.html)
+func (c *Component) PreAuthCode(ctx context.Context) (gjson.Result, error) {
+	return c.PostJSON(ctx, "/cgi-bin/component/api_create_preauthcode", X{"component_appid": c.appid})
+}
+
+// AuthURL 生成授权链接 (PC版)
+//
+//	authType：1-仅公众号，2-仅小程序，3-公众号和小程序，为空时不限制
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/operation/thirdparty/Before_Develop/Authorization_Process_Technical_Description.html)
+func (c *Component) AuthURL(preAuthCode, redirectURI, authType string) string {
+	query := url.Values{}
+
+	query.Set("component_appid", c.appid)
+	query.Set("pre_auth_code", preAuthCode)
+	query.Set("redirect_uri", redirectURI)
+	if len(authType) != 0 {
+		query.Set("auth_type", authType)
+	}
+
+	return "https://mp.weixin.qq.com/cgi-bin/componentloginpage?" + query.Encode()
+}
+
+// QueryAuth 使用授权码获取授权信息，并保存授权方的 access_token 及 refresh_token
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/openApi/OpenApiDoc/ticket-token/getAuthorizerAccessToken.html)
+func (c *Component) QueryAuth(ctx context.Context, authCode string) (gjson.Result, error) {
+	ret, err := c.PostJSON(ctx, "/cgi-bin/component/api_query_auth", X{
+		"component_appid":    c.appid,
+		"authorization_code": authCode,
+	})
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	info := ret.Get("authorization_info")
+	if err = c.saveAuthorizerToken(ctx, info.Get("authorizer_appid").String(), info); err != nil {
+		return internal.Fail(err)
+	}
+	return ret, nil
+}
+
+// AuthorizerAccessToken 使用 refresh_token 获取授权方的 access_token
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/openApi/OpenApiDoc/ticket-token/getAuthorizerAccessToken.html)
+func (c *Component) AuthorizerAccessToken(ctx context.Context, appid, refreshToken string) (gjson.Result, error) {
+	return c.PostJSON(ctx, "/cgi-bin/component/api_authorizer_token", X{
+		"component_appid":          c.appid,
+		"authorizer_appid":         appid,
+		"authorizer_refresh_token": refreshToken,
+	})
+}
+
+// AuthorizerInfo 获取授权方的账号基本信息
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/openApi/OpenApiDoc/authorization-management/getAuthorizerInfo.html)
+func (c *Component) AuthorizerInfo(ctx context.Context, appid string) (gjson.Result, error) {
+	return c.PostJSON(ctx, "/cgi-bin/component/api_get_authorizer_info", X{
+		"component_appid":  c.appid,
+		"authorizer_appid": appid,
+	})
+}
+
+// SetRefreshToken 保存授权方的 refresh_token (如：从已有系统迁移)
+func (c *Component) SetRefreshToken(ctx context.Context, appid, refreshToken string) error {
+	return c.store.Set(ctx, c.refreshTokenKey(appid), &Token{
+		Value:    refreshToken,
+		ExpireAt: time.Now().Add(refreshTokenTTL),
+	})
+}
+
+// RefreshToken 返回授权方的 refresh_token
+func (c *Component) RefreshToken(ctx context.Context, appid string) (string, error) {
+	t, err := c.store.Get(ctx, c.refreshTokenKey(appid))
+	if err != nil {
+		return "", err
+	}
+	if t == nil || len(t.Value) == 0 {
+		return "", fmt.Errorf("authorizer_refresh_token is empty (appid = %s not authorized?)", appid)
+	}
+	return t.Value, nil
+}
+
+// saveAuthorizerToken 保存授权方的 access_token 及 refresh_token (refresh_token 可能变更)
+func (c *Component) saveAuthorizerToken(ctx context.Context, appid string, ret gjson.Result) error {
+	if len(appid) == 0 {
+		return errors.New("authorizer_appid is empty")
+	}
+	if v := ret.Get("authorizer_refresh_token").String(); len(v) != 0 {
+		if err := c.SetRefreshToken(ctx, appid, v); err != nil {
+			return err
+		}
+	}
+	if v := ret.Get("authorizer_access_token").String(); len(v) != 0 {
+		return c.store.Set(ctx, c.authorizerTokenKey(appid), NewToken(v, ret.Get("expires_in").Int()))
+	}
+	return nil
+}
+
+// Code2Session 代小程序获取 session_key 和 openid
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/openApi/OpenApiDoc/miniprogram-management/login/thirdpartyCode2Session.html)
+func (c *Component) Code2Session(ctx context.Context, appid, code string) (gjson.Result, error) {
+	query := url.Values{}
+
+	query.Set("appid", appid)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+	query.Set("component_appid", c.appid)
+
+	return c.GetJSON(ctx, "/sns/component/jscode2session", query)
+}
+
+// Code2OAuthToken 代公众号获取网页授权Token
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/Before_Develop/official_account_website_authorization.html)
+func (c *Component) Code2OAuthToken(ctx context.Context, appid, code string) (gjson.Result, error) {
+	query := url.Values{}
+
+	query.Set("appid", appid)
+	query.Set("code", code)
+	query.Set("grant_type", "authorization_code")
+	query.Set("component_appid", c.appid)
+
+	return c.GetJSON(ctx, "/sns/oauth2/component/access_token", query)
+}
+
+// RefreshOAuthToken 代公众号刷新网页授权Token
+//
+//	[参考](https://developers.weixin.qq.com/doc/oplatform/Third-party_Platforms/2.0/api/Before_Develop/official_account_website_authorization.html)
+func (c *Component) RefreshOAuthToken(ctx context.Context, appid, refreshToken string) (gjson.Result, error) {
+	query := url.Values{}
+
+	query.Set("appid", appid)
+	query.Set("grant_type", "refresh_token")
+	query.Set("refresh_token", refreshToken)
+	query.Set("component_appid", c.appid)
+
+	return c.GetJSON(ctx, "/sns/oauth2/component/refresh_token", query)
+}
+
+// Authorizer 返回授权方
+func (c *Component) Authorizer(appid string) *Authorizer {
+	return &Authorizer{
+		component: c,
+		appid:     appid,
+	}
+}
+
+// Authorizer 第三方平台的授权方 (公众号/小程序)
+type Authorizer struct {
+	component *Component
+	appid     string
+}
+
+// AppID 返回授权方appid
+func (a *Authorizer) AppID() string {
+	return a.appid
+}
+
+func (a *Authorizer) tokenSource() *tokenSource {
+	c := a.component
+
+	return &tokenSource{
+		key:   c.authorizerTokenKey(a.appid),
+		store: c.store,
+		retry: true,
+		lazy:  true,
+		load: func(ctx context.Context, force bool) (*Token, error) {
+			refreshToken, err := c.RefreshToken(ctx, a.appid)
+			if err != nil {
+				return nil, err
+			}
+
+			ret, err := c.AuthorizerAccessToken(ctx, a.appid, refreshToken)
+			if err != nil {
+				return nil, err
+			}
+			if v := ret.Get("authorizer_refresh_token").String(); len(v) != 0 && v != refreshToken {
+				if err = c.SetRefreshToken(ctx, a.appid, v); err != nil {
+					return nil, err
+				}
+			}
+			return NewToken(ret.Get("authorizer_access_token").String(), ret.Get("expires_in").Int()), nil
+		},
+	}
+}
+
+// OfficialAccount 返回代公众号调用的客户端
+//
+//	access_token 通过第三方平台按需获取，无需 AutoLoad；事件消息使用第三方平台的服务器配置
+//	网页授权 (Code2OAuthToken、RefreshOAuthToken) 通过第三方平台代调用
+func (a *Authorizer) OfficialAccount(options ...OAOption) *OfficialAccount {
+	c := a.component
+
+	// 复制服务器配置，避免设置项修改第三方平台及其它授权方的配置
+	srvCfg := *c.srvCfg
+
+	oa := &OfficialAccount{
+		host:      c.host,
+		appid:     a.appid,
+		srvCfg:    &srvCfg,
+		token:     a.tokenSource(),
+		client:    c.client,
+		logger:    c.logger,
+		component: c,
+	}
+	for _, f := range options {
+		f(oa)
+	}
+	oa.initTicket()
+	return oa
+}
+
+// MiniProgram 返回代小程序调用的客户端
+//
+//	access_token 通过第三方平台按需获取，无需 AutoLoad；事件消息使用第三方平台的服务器配置
+//	登录 (Code2Session) 通过第三方平台代调用
+func (a *Authorizer) MiniProgram(options ...MPOption) *MiniProgram {
+	c := a.component
+
+	// 复制服务器配置，避免设置项修改第三方平台及其它授权方的配置
+	srvCfg := *c.srvCfg
+
+	mp := &MiniProgram{
+		host:      c.host,
+		appid:     a.appid,
+		srvCfg:    &srvCfg,
+		sfMode:    newSafeMode(),
+		token:     a.tokenSource(),
+		client:    c.client,
+		logger:    c.logger,
+		component: c,
+
+		watermarkTTL: defaultWatermarkTTL,
+	}
+	for _, f := range options {
+		f(mp)
+	}
+	return mp
+}
+
+// EventServer 返回授权事件回调服务，自动保存推送的 component_verify_ticket
+//
+//	授权变更等回调按 InfoType 注册，如：OnEvent(InfoAuthorized, ...)
+func (c *Component) EventServer() *EventServer {
+	srv := newEventServer([]string{c.appid}, c.srvCfg, false, c.logger)
+	srv.OnEvent(InfoVerifyTicket, func(ctx context.Context, msg *EventMsg) (X, error) {
+		if appid := msg.Get("AppId"); appid != c.appid {
+			return nil, fmt.Errorf("appid mismatch, expect = %s, actual = %s", c.appid, appid)
+		}
+		return nil, c.SetVerifyTicket(ctx, msg.Get("ComponentVerifyTicket"))
+	})
+	return srv
+}
+
+// ComponentOption 第三方平台设置项
+type ComponentOption func(c *Component)
+
+// WithComponentSrvCfg 设置第三方平台消息校验Token及消息加解密Key
+func WithComponentSrvCfg(token, aeskey string) ComponentOption {
+	return func(c *Component) {
+		c.srvCfg.token = token
+		c.srvCfg.aeskey = aeskey
+	}
+}
+
+// WithComponentClient 设置第三方平台请求的 HTTP Client
+func WithComponentClient(cli *http.Client) ComponentOption {
+	return func(c *Component) {
+		c.client = resty.NewWithClient(cli)
+	}
+}
+
+// WithComponentLogger 设置第三方平台日志记录
+func WithComponentLogger(fn func(ctx context.Context, err error, data map[string]string)) ComponentOption {
+	return func(c *Component) {
+		c.logger = fn
+	}
+}
+
+// WithComponentTokenStore 设置 component_verify_ticket 及各类凭据的存储 (默认：内存)
+//
+//	多实例部署时须使用共享存储(如：Redis)；refresh_token 须持久化，丢失后需授权方重新授权
+func WithComponentTokenStore(store TokenStore) ComponentOption {
+	return func(c *Component) {
+		c.store = store
+	}
+}
+
+// NewComponent 生成一个第三方平台实例
+func NewComponent(appid, secret string, options ...ComponentOption) *Component {
+	c := &Component{
+		host:   "https://api.weixin.qq.com",
+		appid:  appid,
+		secret: secret,
+		srvCfg: new(ServerConfig),
+		store:  NewMemTokenStore(),
+		client: internal.NewClient(),
+	}
+	for _, f := range options {
+		f(c)
+	}
+
+	c.token = &tokenSource{
+		key:   "wechat:component:" + appid + ":access_token",
+		store: c.store,
+		retry: true,
+		lazy:  true,
+		load: func(ctx context.Context, force bool) (*Token, error) {
+			ret, err := c.ComponentAccessToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return NewToken(ret.Get("component_access_token").String(), ret.Get("expires_in").Int()), nil
+		},
+	}
+	return c
+}
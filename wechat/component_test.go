@@ -0,0 +1,113 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComponentAuthorizer(t *testing.T) {
+	var refreshes int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/component/api_component_token":
+			w.Write([]byte(`{"component_access_token":"COMPONENT_TOKEN","expires_in":7200}`))
+		case "/cgi-bin/component/api_query_auth":
+			assert.Equal(t, "COMPONENT_TOKEN", r.URL.Query().Get("component_access_token"))
+			w.Write([]byte(`{"authorization_info":{"authorizer_appid":"wxf8b4f85f3a794e77","authorizer_access_token":"OLD_TOKEN","expires_in":7200,"authorizer_refresh_token":"REFRESH_TOKEN"}}`))
+		case "/cgi-bin/component/api_authorizer_token":
+			atomic.AddInt32(&refreshes, 1)
+			w.Write([]byte(`{"authorizer_access_token":"NEW_TOKEN","expires_in":7200,"authorizer_refresh_token":"NEW_REFRESH_TOKEN"}`))
+		case "/sns/component/jscode2session":
+			q := r.URL.Query()
+			assert.Equal(t, "wxf8b4f85f3a794e77", q.Get("appid"))
+			assert.Equal(t, "JS_CODE", q.Get("js_code"))
+			assert.Equal(t, "wx304925fbea25bcbe", q.Get("component_appid"))
+			assert.Equal(t, "COMPONENT_TOKEN", q.Get("component_access_token"))
+			assert.False(t, q.Has("secret"))
+			w.Write([]byte(`{"openid":"OPENID","session_key":"SESSIONKEY"}`))
+		case "/sns/oauth2/component/access_token":
+			q := r.URL.Query()
+			assert.Equal(t, "wxf8b4f85f3a794e77", q.Get("appid"))
+			assert.Equal(t, "CODE", q.Get("code"))
+			assert.Equal(t, "wx304925fbea25bcbe", q.Get("component_appid"))
+			assert.Equal(t, "COMPONENT_TOKEN", q.Get("component_access_token"))
+			assert.False(t, q.Has("secret"))
+			w.Write([]byte(`{"access_token":"OAUTH_TOKEN","openid":"OPENID","refresh_token":"OAUTH_REFRESH"}`))
+		case "/cgi-bin/menu/get":
+			if r.URL.Query().Get(AccessToken) != "NEW_TOKEN" {
+				w.Write([]byte(`{"errcode":40001,"errmsg":"invalid credential"}`))
+				return
+			}
+			w.Write([]byte(`{"is_menu_open":1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	c := NewComponent("wx304925fbea25bcbe", "secret", WithComponentSrvCfg(testEventToken, testEventAESKey))
+	c.host = srv.URL
+
+	// 推送 component_verify_ticket
+	plain := `<xml><AppId><![CDATA[wx304925fbea25bcbe]]></AppId><CreateTime>1413192605</CreateTime><InfoType><![CDATA[component_verify_ticket]]></InfoType><ComponentVerifyTicket><![CDATA[ticket@@@xxx]]></ComponentVerifyTicket></xml>`
+	ct, err := EventEncrypt("wx304925fbea25bcbe", testEventAESKey, "0123456789abcdef", []byte(plain))
+	assert.Nil(t, err)
+
+	query := testEventQuery(ct.String())
+	query.Set("encrypt_type", "aes")
+	body := "<xml><AppId><![CDATA[wx304925fbea25bcbe]]></AppId><Encrypt><![CDATA[" + ct.String() + "]]></Encrypt></xml>"
+
+	w := httptest.NewRecorder()
+	c.EventServer().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/component?"+query.Encode(), strings.NewReader(body)))
+	assert.Equal(t, "success", w.Body.String())
+
+	_, err = c.QueryAuth(ctx, "AUTH_CODE")
+	assert.Nil(t, err)
+
+	// access_token 失效时通过 refresh_token 刷新
+	oa := c.Authorizer("wxf8b4f85f3a794e77").OfficialAccount()
+	ret, err := oa.GetJSON(ctx, "/cgi-bin/menu/get", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ret.Get("is_menu_open").Int())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+
+	refreshToken, err := c.RefreshToken(ctx, "wxf8b4f85f3a794e77")
+	assert.Nil(t, err)
+	assert.Equal(t, "NEW_REFRESH_TOKEN", refreshToken)
+
+	// 网页授权、小程序登录通过第三方平台
+	ret, err = oa.Code2OAuthToken(ctx, "CODE")
+	assert.Nil(t, err)
+	assert.Equal(t, "OAUTH_TOKEN", ret.Get("access_token").String())
+
+	mp := c.Authorizer("wxf8b4f85f3a794e77").MiniProgram()
+	ret, err = mp.Code2Session(ctx, "JS_CODE")
+	assert.Nil(t, err)
+	assert.Equal(t, "SESSIONKEY", ret.Get("session_key").String())
+
+	// 授权方无appsecret
+	_, err = oa.AccessToken(ctx)
+	assert.NotNil(t, err)
+	_, err = mp.StableAccessToken(ctx, false)
+	assert.NotNil(t, err)
+
+	// 设置项不影响第三方平台及其它授权方的服务器配置
+	c.Authorizer("wxf8b4f85f3a794e77").OfficialAccount(WithOASrvCfg("OA_TOKEN", "OA_AESKEY"))
+	c.Authorizer("wxf8b4f85f3a794e77").MiniProgram(WithMPSrvCfg("MP_TOKEN", "MP_AESKEY"))
+	assert.Equal(t, testEventToken, c.srvCfg.token)
+	assert.Equal(t, testEventAESKey, c.srvCfg.aeskey)
+	assert.Equal(t, testEventToken, c.Authorizer("wx0000000000000000").OfficialAccount().srvCfg.token)
+
+	// 未授权的appid
+	_, err = c.Authorizer("wx0000000000000000").MiniProgram().GetJSON(ctx, "/cgi-bin/menu/get", nil)
+	assert.NotNil(t, err)
+}
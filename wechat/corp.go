@@ -9,13 +9,13 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/tidwall/gjson"
 
 	"github.com/yiigo/sdk-go/internal"
+	"github.com/yiigo/sdk-go/internal/xhash"
 )
 
 // Corp 企业微信(企业内部开发)
@@ -24,9 +24,12 @@ type Corp struct {
 	corpid string
 	secret string
 	srvCfg *ServerConfig
-	token  atomic.Value
+	token  *tokenSource
 	client *resty.Client
-	logger func(ctx context.Context, err error, data map[string]string)
+
+	jsapiTicket *tokenSource // 企业jsapi_ticket
+	agentTicket *tokenSource // 应用jsapi_ticket
+	logger      func(ctx context.Context, err error, data map[string]string)
 }
 
 // AppID 返回AppID
@@ -88,7 +91,7 @@ func (c *Corp) do(ctx context.Context, method, path string, header http.Header,
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
 	}
 	return resp.Body(), nil
 }
@@ -122,221 +125,209 @@ func (c *Corp) AccessToken(ctx context.Context) (gjson.Result, error) {
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // AutoLoadAccessToken 自动加载AccessToken
-func (c *Corp) AutoLoadAccessToken(fn func(ctx context.Context, c *Corp) (string, error), interval time.Duration) error {
-	ctx := context.Background()
-
-	// 初始化AccessToken
-	token, err := fn(ctx, c)
-	if err != nil {
-		return err
-	}
-	c.token.Store(token)
-
-	// 异步定时加载
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			_token, _ := fn(ctx, c)
-			if len(token) != 0 {
-				c.token.Store(_token)
-			}
-		}
-	}(ctx)
-
-	return nil
-}
-
-func (c *Corp) getToken() (string, error) {
-	v := c.token.Load()
-	if v == nil {
-		return "", errors.New("access_token is empty (forgotten auto load?)")
-	}
-	token, ok := v.(string)
-	if !ok {
-		return "", errors.New("access_token is not a string")
+//
+//	AccessToken 临近过期时刷新，多实例共享存储时仅一个实例刷新 (见 WithCorpTokenStore)
+//	ctx 取消后停止加载；加载失败时会按指数退避重试，错误通过 Logger 上报
+func (c *Corp) AutoLoadAccessToken(ctx context.Context, fn func(ctx context.Context, c *Corp) (*Token, error), interval time.Duration) error {
+	c.token.load = func(ctx context.Context, force bool) (*Token, error) {
+		return fn(ctx, c)
 	}
-	return token, nil
+	return c.token.autoLoad(ctx, interval, internal.ErrLog("auto_load_access_token", c.logger))
 }
 
 // GetJSON GET请求JSON数据
 func (c *Corp) GetJSON(ctx context.Context, path string, query url.Values) (gjson.Result, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	b, err := c.do(ctx, http.MethodGet, path, nil, query, nil)
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return c.do(ctx, http.MethodGet, path, nil, query, nil)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // PostJSON POST请求JSON数据
 func (c *Corp) PostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
 	header := http.Header{}
 	header.Set(internal.HeaderContentType, internal.ContentJSON)
 
-	b, err := c.do(ctx, http.MethodPost, path, header, query, params)
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return c.do(ctx, http.MethodPost, path, header, query, params)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // GetBuffer GET请求获取buffer (如：获取媒体资源)
 func (c *Corp) GetBuffer(ctx context.Context, path string, query url.Values) ([]byte, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, err
-	}
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	b, err := c.do(ctx, http.MethodGet, path, nil, query, nil)
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return c.do(ctx, http.MethodGet, path, nil, query, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
 
 // PostBuffer POST请求获取buffer (如：获取二维码)
 func (c *Corp) PostBuffer(ctx context.Context, path string, params X) ([]byte, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, err
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
 	header := http.Header{}
 	header.Set(internal.HeaderContentType, internal.ContentJSON)
 
-	b, err := c.do(ctx, http.MethodPost, path, header, query, params)
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return c.do(ctx, http.MethodPost, path, header, query, params)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
 
 // Upload 上传媒体资源
 func (c *Corp) Upload(ctx context.Context, reqPath, fieldName, filePath string, formData Form, query url.Values) (gjson.Result, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
-
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	reqURL := c.url(reqPath, query)
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
 
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, c.logger)
+		reqURL := c.url(reqPath, query)
 
-	resp, err := c.client.R().
-		SetContext(ctx).
-		SetFile(fieldName, filePath).
-		SetFormData(formData).
-		Post(reqURL)
+		log := internal.NewReqLog(http.MethodPost, reqURL)
+		defer log.Do(ctx, c.logger)
+
+		resp, err := c.client.R().
+			SetContext(ctx).
+			SetFile(fieldName, filePath).
+			SetFormData(formData).
+			Post(reqURL)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetRespHeader(resp.Header())
+		log.SetStatusCode(resp.StatusCode())
+		log.SetRespBody(string(resp.Body()))
+		if !resp.IsSuccess() {
+			return nil, &APIError{HTTPStatus: resp.StatusCode()}
+		}
+		return resp.Body(), nil
+	})
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
 
-	ret := gjson.ParseBytes(resp.Body())
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // UploadWithReader 上传媒体资源
 func (c *Corp) UploadWithReader(ctx context.Context, reqPath, fieldName, fileName string, reader io.Reader, formData Form, query url.Values) (gjson.Result, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
-
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	reqURL := c.url(reqPath, query)
+	// 重试时需将 reader 重置到初始位置，无法重置时返回错误
+	seeker, seekable := reader.(io.Seeker)
+	offset := int64(0)
+	if seekable {
+		n, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return internal.Fail(err)
+		}
+		offset = n
+	}
+	attempts := 0
 
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, c.logger)
+	b, err := c.token.do(ctx, func(token string) ([]byte, error) {
+		if attempts++; attempts > 1 {
+			if !seekable {
+				return nil, errors.New("reader is not seekable, cannot retry")
+			}
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		query.Set(AccessToken, token)
 
-	resp, err := c.client.R().
-		SetContext(ctx).
-		SetMultipartField(fieldName, fileName, "", reader).
-		SetFormData(formData).
-		Post(reqURL)
+		reqURL := c.url(reqPath, query)
+
+		log := internal.NewReqLog(http.MethodPost, reqURL)
+		defer log.Do(ctx, c.logger)
+
+		resp, err := c.client.R().
+			SetContext(ctx).
+			SetMultipartField(fieldName, fileName, "", reader).
+			SetFormData(formData).
+			Post(reqURL)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetRespHeader(resp.Header())
+		log.SetStatusCode(resp.StatusCode())
+		log.SetRespBody(string(resp.Body()))
+		if !resp.IsSuccess() {
+			return nil, &APIError{HTTPStatus: resp.StatusCode()}
+		}
+		return resp.Body(), nil
+	})
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
 
-	ret := gjson.ParseBytes(resp.Body())
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
@@ -400,6 +391,22 @@ func WithCorpLogger(fn func(ctx context.Context, err error, data map[string]stri
 	}
 }
 
+// WithCorpTokenStore 设置AccessToken存储 (默认：内存)
+//
+//	多实例部署时，使用共享存储(如：Redis)可避免各实例分别刷新导致AccessToken互相失效
+func WithCorpTokenStore(store TokenStore) CorpOption {
+	return func(c *Corp) {
+		c.token.store = store
+	}
+}
+
+// WithCorpTokenRetry 设置AccessToken失效(errcode：40001、40014、42001)时是否强制刷新并重试一次 (默认：开启)
+func WithCorpTokenRetry(enable bool) CorpOption {
+	return func(c *Corp) {
+		c.token.retry = enable
+	}
+}
+
 // NewCorp 生成一个企业微信(企业内部开发)实例
 func NewCorp(corpid, secret string, options ...CorpOption) *Corp {
 	c := &Corp{
@@ -407,10 +414,16 @@ func NewCorp(corpid, secret string, options ...CorpOption) *Corp {
 		corpid: corpid,
 		secret: secret,
 		srvCfg: new(ServerConfig),
+		token: &tokenSource{
+			key:   "wechat:corp:" + corpid + ":" + xhash.MD5(secret)[:8] + ":access_token",
+			store: NewMemTokenStore(),
+			retry: true,
+		},
 		client: internal.NewClient(),
 	}
 	for _, f := range options {
 		f(c)
 	}
+	c.initTicket()
 	return c
 }
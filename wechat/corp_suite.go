@@ -0,0 +1,395 @@
+package wechat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal"
+)
+
+// suiteTicketTTL suite_ticket 有效期 (每10分钟推送一次，有效期30分钟)
+const suiteTicketTTL = 30 * time.Minute
+
+// CorpSuite 企业微信第三方应用 (服务商)
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90597)
+type CorpSuite struct {
+	host           string
+	suiteID        string
+	secret         string
+	providerCorpID string
+	providerSecret string
+	srvCfg         *ServerConfig
+	store          TokenStore   // suite_ticket 及各类凭据的存储
+	token          *tokenSource // suite_access_token
+	provider       *tokenSource // provider_access_token
+	client         *resty.Client
+	logger         func(ctx context.Context, err error, data map[string]string)
+}
+
+// SuiteID 返回SuiteID
+func (s *CorpSuite) SuiteID() string {
+	return s.suiteID
+}
+
+// Secret 返回SuiteSecret
+func (s *CorpSuite) Secret() string {
+	return s.secret
+}
+
+func (s *CorpSuite) url(path string, query url.Values) string {
+	var builder strings.Builder
+
+	builder.WriteString(s.host)
+	if len(path) != 0 && path[0] != '/' {
+		builder.WriteString("/")
+	}
+	builder.WriteString(path)
+	if len(query) != 0 {
+		builder.WriteString("?")
+		builder.WriteString(query.Encode())
+	}
+
+	return builder.String()
+}
+
+func (s *CorpSuite) do(ctx context.Context, method, path string, query url.Values, params X) ([]byte, error) {
+	reqURL := s.url(path, query)
+
+	log := internal.NewReqLog(method, reqURL)
+	defer log.Do(ctx, s.logger)
+
+	var (
+		body []byte
+		err  error
+	)
+
+	header := http.Header{}
+	if params != nil {
+		body, err = json.Marshal(params)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetReqBody(string(body))
+		header.Set(internal.HeaderContentType, internal.ContentJSON)
+	}
+
+	resp, err := s.client.R().
+		SetContext(ctx).
+		SetHeaderMultiValues(header).
+		SetBody(body).
+		Execute(method, reqURL)
+	if err != nil {
+		log.SetError(err)
+		return nil, err
+	}
+	log.SetRespHeader(resp.Header())
+	log.SetStatusCode(resp.StatusCode())
+	log.SetRespBody(string(resp.Body()))
+	if !resp.IsSuccess() {
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
+	}
+	return resp.Body(), nil
+}
+
+func (s *CorpSuite) post(ctx context.Context, path string, query url.Values, params X) (gjson.Result, error) {
+	b, err := s.do(ctx, http.MethodPost, path, query, params)
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
+	}
+	return ret, nil
+}
+
+func (s *CorpSuite) ticketKey() string {
+	return "wechat:corp_suite:" + s.suiteID + ":suite_ticket"
+}
+
+// SetSuiteTicket 保存回调推送的 suite_ticket (使用 EventServer 时自动保存)
+func (s *CorpSuite) SetSuiteTicket(ctx context.Context, ticket string) error {
+	return s.store.Set(ctx, s.ticketKey(), &Token{
+		Value:    ticket,
+		ExpireAt: time.Now().Add(suiteTicketTTL),
+	})
+}
+
+// SuiteTicket 返回最近一次推送的 suite_ticket
+func (s *CorpSuite) SuiteTicket(ctx context.Context) (string, error) {
+	t, err := s.store.Get(ctx, s.ticketKey())
+	if err != nil {
+		return "", err
+	}
+	if t == nil || len(t.Value) == 0 {
+		return "", errors.New("suite_ticket is empty (callback not received?)")
+	}
+	return t.Value, nil
+}
+
+// SuiteAccessToken 获取第三方应用凭证
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90600)
+func (s *CorpSuite) SuiteAccessToken(ctx context.Context) (gjson.Result, error) {
+	ticket, err := s.SuiteTicket(ctx)
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	return s.post(ctx, "/cgi-bin/service/get_suite_token", nil, X{
+		"suite_id":     s.suiteID,
+		"suite_secret": s.secret,
+		"suite_ticket": ticket,
+	})
+}
+
+// ProviderAccessToken 获取服务商凭证 (需设置 WithCorpSuiteProvider)
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/91200)
+func (s *CorpSuite) ProviderAccessToken(ctx context.Context) (gjson.Result, error) {
+	if len(s.providerCorpID) == 0 {
+		return internal.Fail(errors.New("provider not configured (forgotten WithCorpSuiteProvider?)"))
+	}
+
+	return s.post(ctx, "/cgi-bin/service/get_provider_token", nil, X{
+		"corpid":          s.providerCorpID,
+		"provider_secret": s.providerSecret,
+	})
+}
+
+// GetJSON 使用 suite_access_token 发起GET请求
+func (s *CorpSuite) GetJSON(ctx context.Context, path string, query url.Values) (gjson.Result, error) {
+	return s.request(ctx, s.token, "suite_access_token", http.MethodGet, path, query, nil)
+}
+
+// PostJSON 使用 suite_access_token 发起POST请求
+func (s *CorpSuite) PostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
+	return s.request(ctx, s.token, "suite_access_token", http.MethodPost, path, nil, params)
+}
+
+// ProviderGetJSON 使用 provider_access_token 发起GET请求
+func (s *CorpSuite) ProviderGetJSON(ctx context.Context, path string, query url.Values) (gjson.Result, error) {
+	return s.request(ctx, s.provider, "provider_access_token", http.MethodGet, path, query, nil)
+}
+
+// ProviderPostJSON 使用 provider_access_token 发起POST请求
+func (s *CorpSuite) ProviderPostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
+	return s.request(ctx, s.provider, "provider_access_token", http.MethodPost, path, nil, params)
+}
+
+func (s *CorpSuite) request(ctx context.Context, ts *tokenSource, name, method, path string, query url.Values, params X) (gjson.Result, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	b, err := ts.do(ctx, func(token string) ([]byte, error) {
+		query.Set(name, token)
+		return s.do(ctx, method, path, query, params)
+	})
+	if err != nil {
+		return internal.Fail(err)
+	}
+
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
+	}
+	return ret, nil
+}
+
+// PreAuthCode 获取预授权码
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90601)
+func (s *CorpSuite) PreAuthCode(ctx context.Context) (gjson.Result, error) {
+	return s.GetJSON(ctx, "/cgi-bin/service/get_pre_auth_code", nil)
+}
+
+// InstallURL 生成应用授权安装链接
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90597)
+func (s *CorpSuite) InstallURL(preAuthCode, redirectURI, state string) string {
+	query := url.Values{}
+
+	query.Set("suite_id", s.suiteID)
+	query.Set("pre_auth_code", preAuthCode)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("state", state)
+
+	return "https://open.work.weixin.qq.com/3rdapp/install?" + query.Encode()
+}
+
+// PermanentCode 获取企业永久授权码 (auth_corp_info.corpid、permanent_code)
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90603)
+func (s *CorpSuite) PermanentCode(ctx context.Context, authCode string) (gjson.Result, error) {
+	return s.PostJSON(ctx, "/cgi-bin/service/get_permanent_code", X{"auth_code": authCode})
+}
+
+// AuthInfo 获取企业授权信息
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90604)
+func (s *CorpSuite) AuthInfo(ctx context.Context, corpid, permanentCode string) (gjson.Result, error) {
+	return s.PostJSON(ctx, "/cgi-bin/service/get_auth_info", X{
+		"auth_corpid":    corpid,
+		"permanent_code": permanentCode,
+	})
+}
+
+// CorpAccessToken 获取授权企业的 access_token
+//
+//	[参考](https://developer.work.weixin.qq.com/document/path/90605)
+func (s *CorpSuite) CorpAccessToken(ctx context.Context, corpid, permanentCode string) (gjson.Result, error) {
+	return s.PostJSON(ctx, "/cgi-bin/service/get_corp_token", X{
+		"auth_corpid":    corpid,
+		"permanent_code": permanentCode,
+	})
+}
+
+// Corp 返回授权企业的 Corp 客户端
+//
+//	access_token 通过 get_corp_token 按需获取并保存至 suite 的凭据存储，无需 AutoLoad
+//	客户端较轻量，可按授权企业按需创建
+func (s *CorpSuite) Corp(corpid, permanentCode string, options ...CorpOption) *Corp {
+	c := &Corp{
+		host:   s.host,
+		corpid: corpid,
+		srvCfg: new(ServerConfig),
+		token: &tokenSource{
+			key:   "wechat:corp_suite:" + s.suiteID + ":" + corpid + ":access_token",
+			store: s.store,
+			retry: true,
+			lazy:  true,
+			load: func(ctx context.Context, force bool) (*Token, error) {
+				ret, err := s.CorpAccessToken(ctx, corpid, permanentCode)
+				if err != nil {
+					return nil, err
+				}
+				return NewToken(ret.Get("access_token").String(), ret.Get("expires_in").Int()), nil
+			},
+		},
+		client: s.client,
+		logger: s.logger,
+	}
+	for _, f := range options {
+		f(c)
+	}
+	c.initTicket()
+	return c
+}
+
+// EventServer 返回第三方应用回调服务，自动保存推送的 suite_ticket
+//
+//	授权变更等回调按 InfoType 注册，如：OnEvent(InfoCreateAuth, ...)
+func (s *CorpSuite) EventServer() *EventServer {
+	receiveIDs := []string{s.suiteID}
+	if len(s.providerCorpID) != 0 {
+		receiveIDs = append(receiveIDs, s.providerCorpID)
+	}
+
+	srv := newEventServer(receiveIDs, s.srvCfg, true, s.logger)
+	srv.OnEvent(InfoSuiteTicket, func(ctx context.Context, msg *EventMsg) (X, error) {
+		if id := msg.Get("SuiteId"); id != s.suiteID {
+			return nil, fmt.Errorf("suite_id mismatch, expect = %s, actual = %s", s.suiteID, id)
+		}
+		return nil, s.SetSuiteTicket(ctx, msg.Get("SuiteTicket"))
+	})
+	return srv
+}
+
+// CorpSuiteOption 第三方应用设置项
+type CorpSuiteOption func(s *CorpSuite)
+
+// WithCorpSuiteSrvCfg 设置第三方应用回调配置
+func WithCorpSuiteSrvCfg(token, aeskey string) CorpSuiteOption {
+	return func(s *CorpSuite) {
+		s.srvCfg.token = token
+		s.srvCfg.aeskey = aeskey
+	}
+}
+
+// WithCorpSuiteProvider 设置服务商 corpid 及 provider_secret
+func WithCorpSuiteProvider(corpid, secret string) CorpSuiteOption {
+	return func(s *CorpSuite) {
+		s.providerCorpID = corpid
+		s.providerSecret = secret
+	}
+}
+
+// WithCorpSuiteClient 设置第三方应用请求的 HTTP Client
+func WithCorpSuiteClient(cli *http.Client) CorpSuiteOption {
+	return func(s *CorpSuite) {
+		s.client = resty.NewWithClient(cli)
+	}
+}
+
+// WithCorpSuiteLogger 设置第三方应用日志记录
+func WithCorpSuiteLogger(fn func(ctx context.Context, err error, data map[string]string)) CorpSuiteOption {
+	return func(s *CorpSuite) {
+		s.logger = fn
+	}
+}
+
+// WithCorpSuiteTokenStore 设置 suite_ticket 及各类凭据的存储 (默认：内存)
+//
+//	多实例部署时须使用共享存储(如：Redis)，suite_ticket 仅推送至其中一个实例
+func WithCorpSuiteTokenStore(store TokenStore) CorpSuiteOption {
+	return func(s *CorpSuite) {
+		s.store = store
+	}
+}
+
+// NewCorpSuite 生成一个企业微信第三方应用实例
+func NewCorpSuite(suiteID, secret string, options ...CorpSuiteOption) *CorpSuite {
+	s := &CorpSuite{
+		host:    "https://qyapi.weixin.qq.com",
+		suiteID: suiteID,
+		secret:  secret,
+		srvCfg:  new(ServerConfig),
+		store:   NewMemTokenStore(),
+		client:  internal.NewClient(),
+	}
+	for _, f := range options {
+		f(s)
+	}
+
+	s.token = &tokenSource{
+		key:     "wechat:corp_suite:" + suiteID + ":suite_access_token",
+		store:   s.store,
+		retry:   true,
+		lazy:    true,
+		expired: isSuiteTokenExpired,
+		load: func(ctx context.Context, force bool) (*Token, error) {
+			ret, err := s.SuiteAccessToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return NewToken(ret.Get("suite_access_token").String(), ret.Get("expires_in").Int()), nil
+		},
+	}
+	s.provider = &tokenSource{
+		key:   "wechat:corp_provider:" + s.providerCorpID + ":provider_access_token",
+		store: s.store,
+		retry: true,
+		lazy:  true,
+		load: func(ctx context.Context, force bool) (*Token, error) {
+			ret, err := s.ProviderAccessToken(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return NewToken(ret.Get("provider_access_token").String(), ret.Get("expires_in").Int()), nil
+		},
+	}
+	return s
+}
@@ -0,0 +1,71 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestCorpSuite(t *testing.T) {
+	var suiteLoads, corpLoads int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/service/get_suite_token":
+			atomic.AddInt32(&suiteLoads, 1)
+			w.Write([]byte(`{"errcode":0,"suite_access_token":"SUITE_TOKEN","expires_in":7200}`))
+		case "/cgi-bin/service/get_corp_token":
+			atomic.AddInt32(&corpLoads, 1)
+			assert.Equal(t, "SUITE_TOKEN", r.URL.Query().Get("suite_access_token"))
+			w.Write([]byte(`{"errcode":0,"access_token":"CORP_TOKEN","expires_in":7200}`))
+		case "/cgi-bin/user/get":
+			assert.Equal(t, "CORP_TOKEN", r.URL.Query().Get(AccessToken))
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok","userid":"zhangsan"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	suite := NewCorpSuite("ww4asffe99e54c0fxx", "secret", WithCorpSuiteSrvCfg(testEventToken, testEventAESKey))
+	suite.host = srv.URL
+
+	// 未收到 suite_ticket
+	_, err := suite.PreAuthCode(ctx)
+	assert.NotNil(t, err)
+
+	// 回调推送 suite_ticket
+	plain := `<xml><SuiteId><![CDATA[ww4asffe99e54c0fxx]]></SuiteId><InfoType><![CDATA[suite_ticket]]></InfoType><TimeStamp>1403610513</TimeStamp><SuiteTicket><![CDATA[asdfasfdasdfasdf]]></SuiteTicket></xml>`
+	ct, err := EventEncrypt("ww4asffe99e54c0fxx", testEventAESKey, "0123456789abcdef", []byte(plain))
+	assert.Nil(t, err)
+
+	query := testEventQuery(ct.String())
+	body := "<xml><ToUserName><![CDATA[ww4asffe99e54c0fxx]]></ToUserName><Encrypt><![CDATA[" + ct.String() + "]]></Encrypt></xml>"
+
+	w := httptest.NewRecorder()
+	suite.EventServer().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/suite?"+query.Encode(), strings.NewReader(body)))
+	assert.Equal(t, "success", w.Body.String())
+
+	ticket, err := suite.SuiteTicket(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "asdfasfdasdfasdf", ticket)
+
+	// 授权企业按需获取 access_token
+	var ret gjson.Result
+	for i := 0; i < 3; i++ {
+		corp := suite.Corp("wxf8b4f85f3a794e77", "PERMANENT_CODE")
+		ret, err = corp.GetJSON(ctx, "/cgi-bin/user/get", nil)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, "zhangsan", ret.Get("userid").String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&suiteLoads))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&corpLoads))
+}
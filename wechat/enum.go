@@ -16,6 +16,9 @@ const (
 	HeaderMPSignatureDeprecated = "Wechatmp-Signature-Deprecated"
 )
 
+// PayPublicKeyIDPrefix 微信支付公钥ID前缀
+const PayPublicKeyIDPrefix = "PUB_KEY_ID_"
+
 // SignAlgo 签名算法
 type SignAlgo string
 
@@ -72,4 +75,56 @@ const (
 	InvalidRequest     = "INVALID_REQUEST"       // 无效请求
 	TradeError         = "TRADE_ERROR"           // 交易错误
 	URLFormatError     = "URLFORMATERROR"        // URL格式错误
+	FrequencyLimited   = "FREQUENCY_LIMITED"     // 频率限制
+)
+
+// 事件消息类型 (MsgType)
+const (
+	MsgText       = "text"                      // 文本消息
+	MsgImage      = "image"                     // 图片消息
+	MsgVoice      = "voice"                     // 语音消息
+	MsgVideo      = "video"                     // 视频消息
+	MsgShortVideo = "shortvideo"                // 小视频消息
+	MsgLocation   = "location"                  // 地理位置消息
+	MsgLink       = "link"                      // 链接消息
+	MsgNews       = "news"                      // 图文消息 (回复)
+	MsgEvent      = "event"                     // 事件推送
+	MsgTransferCS = "transfer_customer_service" // 转发到客服 (回复)
+)
+
+// 事件类型 (Event)
+const (
+	EventSubscribe       = "subscribe"                     // 关注
+	EventUnsubscribe     = "unsubscribe"                   // 取消关注
+	EventScan            = "SCAN"                          // 已关注用户扫描带参数二维码
+	EventLocation        = "LOCATION"                      // 上报地理位置
+	EventClick           = "CLICK"                         // 点击菜单拉取消息
+	EventView            = "VIEW"                          // 点击菜单跳转链接
+	EventScancodePush    = "scancode_push"                 // 扫码推事件
+	EventScancodeWait    = "scancode_waitmsg"              // 扫码推事件且弹出“消息接收中”提示框
+	EventPicSysPhoto     = "pic_sysphoto"                  // 弹出系统拍照发图
+	EventPicPhotoAlbum   = "pic_photo_or_album"            // 弹出拍照或者相册发图
+	EventPicWeixin       = "pic_weixin"                    // 弹出微信相册发图器
+	EventLocationSelect  = "location_select"               // 弹出地理位置选择器
+	EventChangeContact   = "change_contact"                // 通讯录变更 (企业微信)
+	EventEnterAgent      = "enter_agent"                   // 进入应用 (企业微信)
+	EventMediaCheck      = "wxa_media_check"               // 音视频内容安全识别结果 (小程序)
+	EventTradeSettlement = "trade_manage_order_settlement" // 订单完成发货及结算 (小程序发货信息管理)
+)
+
+// 企业微信第三方应用回调类型 (InfoType)
+const (
+	InfoSuiteTicket   = "suite_ticket"         // 推送suite_ticket
+	InfoCreateAuth    = "create_auth"          // 授权成功
+	InfoChangeAuth    = "change_auth"          // 变更授权
+	InfoCancelAuth    = "cancel_auth"          // 取消授权
+	InfoResetPermCode = "reset_permanent_code" // 重置永久授权码
+)
+
+// 第三方平台授权事件类型 (InfoType)
+const (
+	InfoVerifyTicket     = "component_verify_ticket" // 推送component_verify_ticket
+	InfoAuthorized       = "authorized"              // 授权成功
+	InfoUpdateAuthorized = "updateauthorized"        // 授权更新
+	InfoUnauthorized     = "unauthorized"            // 取消授权
 )
@@ -0,0 +1,111 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/tidwall/gjson"
+)
+
+// APIError 微信接口错误
+//
+//	通过 errors.As 获取：
+//	var e *wechat.APIError
+//	if errors.As(err, &e) { ... }
+type APIError struct {
+	HTTPStatus int    // HTTP状态码
+	Code       int64  // 错误码 (errcode)
+	ErrCode    string // 支付错误码 (v2：return_code/err_code，v3：code)
+	Msg        string // 错误信息 (errmsg/return_msg/err_code_des/message)
+	Detail     string // 错误详情 (支付v3：detail；支付v2：下载账单失败时的 error_code)
+	RID        string // 请求ID (errmsg 中的 rid 或 Request-ID)
+}
+
+func (e *APIError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%d | %s", e.Code, e.Msg)
+	}
+	if len(e.ErrCode) != 0 {
+		if len(e.Detail) != 0 {
+			return fmt.Sprintf("%s | %s (detail = %s)", e.ErrCode, e.Msg, e.Detail)
+		}
+		return fmt.Sprintf("%s | %s", e.ErrCode, e.Msg)
+	}
+	return fmt.Sprintf("HTTP Request Error, StatusCode = %d", e.HTTPStatus)
+}
+
+// IsTokenExpired 是否为AccessToken失效(errcode：40001、40014、42001)
+func IsTokenExpired(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && isTokenExpired(e.Code)
+}
+
+// IsRateLimited 是否为调用频率受限
+func IsRateLimited(err error) bool {
+	var e *APIError
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Code {
+	case 45009, // 接口调用超过限制
+		45011, // API 调用太频繁，请稍候再试
+		45047: // 客服接口下行条数超过上限
+		return true
+	}
+	return e.HTTPStatus == http.StatusTooManyRequests || e.ErrCode == FrequencyLimited
+}
+
+// newAPIError 根据 errcode/errmsg 生成错误
+func newAPIError(ret gjson.Result) *APIError {
+	msg := ret.Get("errmsg").String()
+
+	e := &APIError{
+		HTTPStatus: http.StatusOK,
+		Code:       ret.Get("errcode").Int(),
+		Msg:        msg,
+	}
+	// 如：invalid credential, access_token is invalid or not latest rid: 6530b4c2-1b5d4f7e-0a1c2d3e
+	if i := strings.LastIndex(msg, "rid:"); i >= 0 {
+		e.RID = strings.TrimSpace(msg[i+4:])
+	}
+	return e
+}
+
+// newPayError 根据支付v2返回结果生成错误，成功时返回 nil
+func newPayError(ret V) error {
+	if code := ret.Get("return_code"); code != ResultSuccess {
+		return &APIError{
+			HTTPStatus: http.StatusOK,
+			ErrCode:    code,
+			Msg:        ret.Get("return_msg"),
+			Detail:     ret.Get("error_code"), // 如：下载账单失败时的错误码
+		}
+	}
+	if ret.Get("result_code") == ResultFail {
+		return &APIError{
+			HTTPStatus: http.StatusOK,
+			ErrCode:    ret.Get("err_code"),
+			Msg:        ret.Get("err_code_des"),
+		}
+	}
+	return nil
+}
+
+// newPayV3Error 根据支付v3的错误应答生成错误
+func newPayV3Error(resp *resty.Response, body []byte) *APIError {
+	ret := gjson.ParseBytes(body)
+
+	e := &APIError{
+		HTTPStatus: resp.StatusCode(),
+		ErrCode:    ret.Get("code").String(),
+		Msg:        ret.Get("message").String(),
+		RID:        resp.Header().Get(HeaderRequestID),
+	}
+	if detail := ret.Get("detail"); detail.Exists() {
+		e.Detail = detail.Raw
+	}
+	return e
+}
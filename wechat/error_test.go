@@ -0,0 +1,71 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func TestAPIError(t *testing.T) {
+	err := fmt.Errorf("get user info: %w", newAPIError(gjson.Parse(`{"errcode":40001,"errmsg":"invalid credential, access_token is invalid or not latest rid: 6530b4c2-1b5d4f7e-0a1c2d3e"}`)))
+
+	var e *APIError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, int64(40001), e.Code)
+	assert.Equal(t, "6530b4c2-1b5d4f7e-0a1c2d3e", e.RID)
+	assert.Equal(t, "40001 | invalid credential, access_token is invalid or not latest rid: 6530b4c2-1b5d4f7e-0a1c2d3e", e.Error())
+	assert.True(t, IsTokenExpired(err))
+	assert.False(t, IsRateLimited(err))
+
+	assert.True(t, IsRateLimited(newAPIError(gjson.Parse(`{"errcode":45009,"errmsg":"reach max api daily quota limit"}`))))
+	assert.False(t, IsTokenExpired(errors.New("40001 | invalid credential")))
+}
+
+func TestPayError(t *testing.T) {
+	assert.Nil(t, newPayError(V{"return_code": ResultSuccess, "result_code": ResultSuccess}))
+
+	err := newPayError(V{"return_code": ResultSuccess, "result_code": ResultFail, "err_code": OrderPaid, "err_code_des": "订单已支付"})
+
+	var e *APIError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, OrderPaid, e.ErrCode)
+	assert.Equal(t, "ORDERPAID | 订单已支付", err.Error())
+
+	err = newPayError(V{"return_code": ResultFail, "return_msg": "签名错误"})
+	assert.Equal(t, "FAIL | 签名错误", err.Error())
+}
+
+func TestPayPostXMLFail(t *testing.T) {
+	pay := NewPay("10000100", "192006250b4c09247ec02edce69f6a2d")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := V{
+			"return_code":  ResultSuccess,
+			"result_code":  ResultFail,
+			"err_code":     "USERPAYING",
+			"err_code_des": "需要用户输入支付密码",
+			"appid":        "wx2421b1c4370ec43b",
+			"mch_id":       "10000100",
+			"nonce_str":    "GOp3TRyMXzbMlkun",
+		}
+		v.Set("sign", pay.Sign(v))
+		body, _ := ValueToXML(v)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	pay.host = srv.URL
+
+	// result_code 为 FAIL 时同时返回已验签的结果
+	ret, err := pay.PostXML(context.Background(), "/pay/micropay", V{"out_trade_no": "1415757673"})
+
+	var e *APIError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, "USERPAYING", e.ErrCode)
+	assert.Equal(t, "wx2421b1c4370ec43b", ret.Get("appid"))
+}
@@ -5,6 +5,8 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -69,32 +71,82 @@ func EventDecrypt(receiveID, encodingAESKey, cipherText string) ([]byte, error)
 
 	// 校验 receiveid
 	appidOffset := len(plainText) - len([]byte(receiveID))
+	if appidOffset < 20 {
+		return nil, fmt.Errorf("receive_id mismatch, want: %s", receiveID)
+	}
 	if v := string(plainText[appidOffset:]); v != receiveID {
 		return nil, fmt.Errorf("receive_id mismatch, want: %s, got: %s", receiveID, v)
 	}
 	return plainText[20:appidOffset], nil
 }
 
+// eventDecryptAny 事件消息解密，receiveid 为 receiveIDs 之一即可 (如：第三方应用回调)
+func eventDecryptAny(receiveIDs []string, encodingAESKey, cipherText string) ([]byte, error) {
+	var err error
+	for _, id := range receiveIDs {
+		var b []byte
+		if b, err = EventDecrypt(id, encodingAESKey, cipherText); err == nil {
+			return b, nil
+		}
+	}
+	if err == nil {
+		err = errors.New("receive_id is empty")
+	}
+	return nil, err
+}
+
+// EventReply 事件回复加密 (XML)
 func EventReply(receiveID, token, encodingAESKey string, msg V) (V, error) {
 	str, err := ValueToXML(msg)
 	if err != nil {
 		return nil, err
 	}
 
-	nonce := internal.Nonce(16)
-	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	encryptMsg, signature, timestamp, nonce, err := eventReplyEncrypt(receiveID, token, encodingAESKey, []byte(str))
+	if err != nil {
+		return nil, err
+	}
 
-	ct, err := EventEncrypt(receiveID, encodingAESKey, nonce, []byte(str))
+	return V{
+		"Encrypt":      encryptMsg,
+		"MsgSignature": signature,
+		"TimeStamp":    strconv.FormatInt(timestamp, 10),
+		"Nonce":        nonce,
+	}, nil
+}
+
+// EventReplyJSON 事件回复加密 (JSON，如：小程序消息推送数据格式为JSON)
+//
+//	[参考](https://developers.weixin.qq.com/miniprogram/dev/framework/server-ability/message-push.html)
+func EventReplyJSON(receiveID, token, encodingAESKey string, msg X) (X, error) {
+	b, err := json.Marshal(msg)
 	if err != nil {
 		return nil, err
 	}
 
-	encryptMsg := ct.String()
+	encryptMsg, signature, timestamp, nonce, err := eventReplyEncrypt(receiveID, token, encodingAESKey, b)
+	if err != nil {
+		return nil, err
+	}
 
-	return V{
+	return X{
 		"Encrypt":      encryptMsg,
-		"MsgSignature": SignWithSHA1(token, timestamp, nonce, encryptMsg),
+		"MsgSignature": signature,
 		"TimeStamp":    timestamp,
 		"Nonce":        nonce,
 	}, nil
 }
+
+func eventReplyEncrypt(receiveID, token, encodingAESKey string, plainText []byte) (encryptMsg, signature string, timestamp int64, nonce string, err error) {
+	nonce = internal.Nonce(16)
+	timestamp = time.Now().Unix()
+
+	ct, err := EventEncrypt(receiveID, encodingAESKey, nonce, plainText)
+	if err != nil {
+		return
+	}
+
+	encryptMsg = ct.String()
+	signature = SignWithSHA1(token, strconv.FormatInt(timestamp, 10), nonce, encryptMsg)
+	return
+}
@@ -0,0 +1,435 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yiigo/sdk-go/internal"
+)
+
+// EventMsg 事件消息 (已验签、解密)
+type EventMsg struct {
+	ToUserName   string
+	FromUserName string
+	CreateTime   int64
+	MsgType      string
+	Event        string
+	EventKey     string
+	MsgID        string
+	Content      string
+	AgentID      string // 企业微信
+	ChangeType   string // 企业微信通讯录变更
+	InfoType     string // 企业微信第三方应用回调 (如：suite_ticket、create_auth)
+	Data         X      // 完整消息 (嵌套元素为X，重复元素为[]any)
+
+	raw    []byte // 明文消息
+	isJSON bool   // 数据格式是否为JSON (小程序)
+}
+
+// Get 返回指定字段的文本值
+func (m *EventMsg) Get(key string) string {
+	switch v := m.Data[key].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+// Raw 返回明文消息 (XML/JSON)
+func (m *EventMsg) Raw() []byte {
+	return m.raw
+}
+
+// IsJSON 数据格式是否为JSON
+func (m *EventMsg) IsJSON() bool {
+	return m.isJSON
+}
+
+// Decode 将明文消息解析到v (根据数据格式使用 json/xml 标签)
+func (m *EventMsg) Decode(v any) error {
+	if m.isJSON {
+		return json.Unmarshal(m.raw, v)
+	}
+	return xml.Unmarshal(m.raw, v)
+}
+
+func newEventMsg(raw []byte) (*EventMsg, error) {
+	data, isJSON, err := decodeEventData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &EventMsg{
+		Data:   data,
+		raw:    raw,
+		isJSON: isJSON,
+	}
+
+	msg.ToUserName = msg.Get("ToUserName")
+	msg.FromUserName = msg.Get("FromUserName")
+	msg.CreateTime, _ = strconv.ParseInt(msg.Get("CreateTime"), 10, 64)
+	msg.MsgType = msg.Get("MsgType")
+	msg.Event = msg.Get("Event")
+	msg.EventKey = msg.Get("EventKey")
+	msg.MsgID = msg.Get("MsgId")
+	msg.Content = msg.Get("Content")
+	msg.AgentID = msg.Get("AgentID")
+	msg.ChangeType = msg.Get("ChangeType")
+	msg.InfoType = msg.Get("InfoType")
+
+	return msg, nil
+}
+
+// decodeEventData 解析XML/JSON消息 (JSON数字解析为 json.Number)
+func decodeEventData(b []byte) (X, bool, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		x, err := XMLToX(b)
+		return x, false, err
+	}
+
+	x := X{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&x); err != nil {
+		return nil, true, err
+	}
+	return x, true, nil
+}
+
+func (m *EventMsg) reply(msgType string) X {
+	return X{
+		"ToUserName":   m.FromUserName,
+		"FromUserName": m.ToUserName,
+		"CreateTime":   time.Now().Unix(),
+		"MsgType":      msgType,
+	}
+}
+
+// ReplyText 回复文本消息
+func (m *EventMsg) ReplyText(content string) X {
+	x := m.reply(MsgText)
+	x["Content"] = content
+	return x
+}
+
+// ReplyImage 回复图片消息
+func (m *EventMsg) ReplyImage(mediaID string) X {
+	x := m.reply(MsgImage)
+	x["Image"] = X{"MediaId": mediaID}
+	return x
+}
+
+// ReplyVoice 回复语音消息
+func (m *EventMsg) ReplyVoice(mediaID string) X {
+	x := m.reply(MsgVoice)
+	x["Voice"] = X{"MediaId": mediaID}
+	return x
+}
+
+// ReplyVideo 回复视频消息
+func (m *EventMsg) ReplyVideo(mediaID, title, description string) X {
+	x := m.reply(MsgVideo)
+	x["Video"] = X{
+		"MediaId":     mediaID,
+		"Title":       title,
+		"Description": description,
+	}
+	return x
+}
+
+// ReplyArticle 图文消息
+type ReplyArticle struct {
+	Title       string
+	Description string
+	PicURL      string
+	URL         string
+}
+
+// ReplyNews 回复图文消息
+func (m *EventMsg) ReplyNews(articles ...*ReplyArticle) X {
+	items := make([]X, 0, len(articles))
+	for _, v := range articles {
+		items = append(items, X{
+			"Title":       v.Title,
+			"Description": v.Description,
+			"PicUrl":      v.PicURL,
+			"Url":         v.URL,
+		})
+	}
+
+	x := m.reply(MsgNews)
+	x["ArticleCount"] = len(items)
+	x["Articles"] = X{"item": items}
+	return x
+}
+
+// ReplyTransferCustomerService 将消息转发到客服，kfAccount 为空时转发给任意在线客服
+func (m *EventMsg) ReplyTransferCustomerService(kfAccount string) X {
+	x := m.reply(MsgTransferCS)
+	if len(kfAccount) != 0 {
+		x["TransInfo"] = X{"KfAccount": kfAccount}
+	}
+	return x
+}
+
+// EventHandler 事件消息处理函数，返回 nil 时应答 success (不回复)
+type EventHandler func(ctx context.Context, msg *EventMsg) (X, error)
+
+// EventServer 事件消息服务 (公众号、小程序、企业微信回调)
+//
+//	GET：服务器URL验证
+//	POST：验签、解密(兼容模式/安全模式)，按 MsgType/Event 分发，并加密回复
+//	注意：处理函数须在服务启动前注册；返回 error 时响应 500，微信将按重试策略再次推送
+type EventServer struct {
+	receiveIDs []string // 首个用于加密回复
+	srvCfg     *ServerConfig
+	corp       bool
+	logger     func(ctx context.Context, err error, data map[string]string)
+
+	msgHandlers    map[string]EventHandler
+	eventHandlers  map[string]EventHandler
+	defaultHandler EventHandler
+}
+
+func newEventServer(receiveIDs []string, srvCfg *ServerConfig, corp bool, logger func(ctx context.Context, err error, data map[string]string)) *EventServer {
+	return &EventServer{
+		receiveIDs:    receiveIDs,
+		srvCfg:        srvCfg,
+		corp:          corp,
+		logger:        logger,
+		msgHandlers:   make(map[string]EventHandler),
+		eventHandlers: make(map[string]EventHandler),
+	}
+}
+
+// EventServer 返回公众号事件消息服务
+func (oa *OfficialAccount) EventServer() *EventServer {
+	return newEventServer([]string{oa.receiveID()}, oa.srvCfg, false, oa.logger)
+}
+
+// EventServer 返回小程序事件消息服务
+func (mp *MiniProgram) EventServer() *EventServer {
+	return newEventServer([]string{mp.receiveID()}, mp.srvCfg, false, mp.logger)
+}
+
+// EventServer 返回企业微信事件消息服务
+func (c *Corp) EventServer() *EventServer {
+	return newEventServer([]string{c.corpid}, c.srvCfg, true, c.logger)
+}
+
+// OnMsg 注册普通消息处理函数，如：MsgText、MsgImage
+func (s *EventServer) OnMsg(msgType string, h EventHandler) {
+	s.msgHandlers[strings.ToLower(msgType)] = h
+}
+
+// OnEvent 注册事件处理函数，如：EventSubscribe、EventScan、EventChangeContact
+//
+//	第三方应用回调按 InfoType 注册，如：InfoCreateAuth
+func (s *EventServer) OnEvent(event string, h EventHandler) {
+	s.eventHandlers[strings.ToLower(event)] = h
+}
+
+// OnDefault 注册未匹配到处理函数时的默认处理函数
+func (s *EventServer) OnDefault(h EventHandler) {
+	s.defaultHandler = h
+}
+
+func (s *EventServer) handler(msg *EventMsg) EventHandler {
+	var h EventHandler
+	switch {
+	case len(msg.InfoType) != 0:
+		h = s.eventHandlers[strings.ToLower(msg.InfoType)]
+	case msg.MsgType == MsgEvent:
+		h = s.eventHandlers[strings.ToLower(msg.Event)]
+	default:
+		h = s.msgHandlers[strings.ToLower(msg.MsgType)]
+	}
+	if h == nil {
+		h = s.defaultHandler
+	}
+	return h
+}
+
+func (s *EventServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	errLog := internal.ErrLog("event", s.logger)
+
+	switch r.Method {
+	case http.MethodGet:
+		echostr, err := s.verifyURL(r)
+		if err != nil {
+			errLog(ctx, err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		s.write(w, internal.ContentText, echostr)
+	case http.MethodPost:
+		msg, encrypted, err := s.parse(r)
+		if err != nil {
+			errLog(ctx, err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		var reply X
+		if h := s.handler(msg); h != nil {
+			if reply, err = h(ctx, msg); err != nil {
+				errLog(ctx, err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+		if reply == nil {
+			s.write(w, internal.ContentText, "success")
+			return
+		}
+
+		body, err := s.encode(reply, encrypted, msg.isJSON)
+		if err != nil {
+			errLog(ctx, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if msg.isJSON {
+			s.write(w, internal.ContentJSON, body)
+			return
+		}
+		s.write(w, internal.ContentXML, body)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// verifyURL 服务器URL验证，返回应答的 echostr (企业微信需解密)
+func (s *EventServer) verifyURL(r *http.Request) (string, error) {
+	query := r.URL.Query()
+
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+	echostr := query.Get("echostr")
+
+	if !s.corp {
+		if err := s.verify(query.Get("signature"), timestamp, nonce); err != nil {
+			return "", err
+		}
+		return echostr, nil
+	}
+
+	if err := s.verify(query.Get("msg_signature"), timestamp, nonce, echostr); err != nil {
+		return "", err
+	}
+	b, err := eventDecryptAny(s.receiveIDs, s.srvCfg.aeskey, echostr)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// parse 验签并解析消息，encrypted 表示是否为加密消息 (兼容模式/安全模式)
+func (s *EventServer) parse(r *http.Request) (msg *EventMsg, encrypted bool, err error) {
+	query := r.URL.Query()
+
+	timestamp := query.Get("timestamp")
+	nonce := query.Get("nonce")
+
+	// 明文模式及兼容模式均携带 signature (企业微信仅有 msg_signature)
+	if !s.corp {
+		if err = s.verify(query.Get("signature"), timestamp, nonce); err != nil {
+			return
+		}
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+
+	// 明文模式
+	if !s.corp && query.Get("encrypt_type") != "aes" {
+		msg, err = newEventMsg(b)
+		return
+	}
+
+	data, _, err := decodeEventData(b)
+	if err != nil {
+		return
+	}
+	encrypt, _ := data["Encrypt"].(string)
+	if len(encrypt) == 0 {
+		err = errors.New("encrypt is empty")
+		return
+	}
+	if err = s.verify(query.Get("msg_signature"), timestamp, nonce, encrypt); err != nil {
+		return
+	}
+
+	plain, err := eventDecryptAny(s.receiveIDs, s.srvCfg.aeskey, encrypt)
+	if err != nil {
+		return
+	}
+	if msg, err = newEventMsg(plain); err != nil {
+		return
+	}
+
+	encrypted = true
+	return
+}
+
+func (s *EventServer) verify(signature string, items ...string) error {
+	if v := SignWithSHA1(s.srvCfg.token, items...); v != signature {
+		return fmt.Errorf("signature verified fail, expect=%s, actual=%s", signature, v)
+	}
+	return nil
+}
+
+func (s *EventServer) encode(reply X, encrypted, isJSON bool) (string, error) {
+	if isJSON {
+		if encrypted {
+			var err error
+			if reply, err = EventReplyJSON(s.receiveIDs[0], s.srvCfg.token, s.srvCfg.aeskey, reply); err != nil {
+				return "", err
+			}
+		}
+		b, err := json.Marshal(reply)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	str, err := XToXML(reply)
+	if err != nil {
+		return "", err
+	}
+	if !encrypted {
+		return str, nil
+	}
+
+	encryptMsg, signature, timestamp, nonce, err := eventReplyEncrypt(s.receiveIDs[0], s.srvCfg.token, s.srvCfg.aeskey, []byte(str))
+	if err != nil {
+		return "", err
+	}
+	return XToXML(X{
+		"Encrypt":      encryptMsg,
+		"MsgSignature": signature,
+		"TimeStamp":    timestamp,
+		"Nonce":        nonce,
+	})
+}
+
+func (s *EventServer) write(w http.ResponseWriter, contentType, body string) {
+	w.Header().Set(internal.HeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(body))
+}
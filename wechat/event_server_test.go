@@ -0,0 +1,157 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	testEventToken  = "QDG6eK"
+	testEventAESKey = "jWmYm7qr5nMoAUwZRjGtBxmz3KA1tkAj3ykkR6q2B2C"
+)
+
+func testEventQuery(items ...string) url.Values {
+	query := url.Values{}
+	query.Set("timestamp", "1409659813")
+	query.Set("nonce", "1372623149")
+	query.Set("signature", SignWithSHA1(testEventToken, "1409659813", "1372623149"))
+	if len(items) != 0 {
+		query.Set("msg_signature", SignWithSHA1(testEventToken, append([]string{"1409659813", "1372623149"}, items...)...))
+	}
+	return query
+}
+
+func TestEventServerPlain(t *testing.T) {
+	oa := NewOfficialAccount("wx2421b1c4370ec43b", "secret", WithOASrvCfg(testEventToken, testEventAESKey))
+
+	srv := oa.EventServer()
+	srv.OnMsg(MsgText, func(ctx context.Context, msg *EventMsg) (X, error) {
+		return msg.ReplyText("echo: " + msg.Content), nil
+	})
+
+	// 服务器URL验证
+	query := testEventQuery()
+	query.Set("echostr", "4975085474413470396")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/event?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "4975085474413470396", w.Body.String())
+
+	body := `<xml><ToUserName><![CDATA[gh_123]]></ToUserName><FromUserName><![CDATA[oUser]]></FromUserName><CreateTime>1348831860</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[hello]]></Content><MsgId>1234567890123456</MsgId></xml>`
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event?"+testEventQuery().Encode(), strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	reply, err := XMLToX(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, "oUser", reply["ToUserName"])
+	assert.Equal(t, "echo: hello", reply["Content"])
+
+	// 未注册的消息类型
+	body = strings.Replace(body, "text", "image", 1)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event?"+testEventQuery().Encode(), strings.NewReader(body)))
+	assert.Equal(t, "success", w.Body.String())
+
+	// 签名错误
+	query = testEventQuery()
+	query.Set("signature", "invalid")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event?"+query.Encode(), strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusBadRequest)+"\n", w.Body.String())
+}
+
+func TestEventServerSafe(t *testing.T) {
+	mp := NewMiniProgram("wx2421b1c4370ec43b", "secret", WithMPSrvCfg(testEventToken, testEventAESKey))
+
+	srv := mp.EventServer()
+	srv.OnEvent(EventSubscribe, func(ctx context.Context, msg *EventMsg) (X, error) {
+		assert.Equal(t, "qrscene_123", msg.EventKey)
+		return msg.ReplyNews(&ReplyArticle{Title: "welcome", URL: "https://example.com"}), nil
+	})
+
+	plain := `<xml><ToUserName><![CDATA[gh_123]]></ToUserName><FromUserName><![CDATA[oUser]]></FromUserName><CreateTime>1348831860</CreateTime><MsgType><![CDATA[event]]></MsgType><Event><![CDATA[subscribe]]></Event><EventKey><![CDATA[qrscene_123]]></EventKey></xml>`
+	ct, err := EventEncrypt("wx2421b1c4370ec43b", testEventAESKey, "0123456789abcdef", []byte(plain))
+	assert.Nil(t, err)
+
+	query := testEventQuery(ct.String())
+	query.Set("encrypt_type", "aes")
+	body := "<xml><ToUserName><![CDATA[gh_123]]></ToUserName><Encrypt><![CDATA[" + ct.String() + "]]></Encrypt></xml>"
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event?"+query.Encode(), strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	reply, err := XMLToX(w.Body.Bytes())
+	assert.Nil(t, err)
+
+	encrypt := reply["Encrypt"].(string)
+	assert.Equal(t, SignWithSHA1(testEventToken, reply["TimeStamp"].(string), reply["Nonce"].(string), encrypt), reply["MsgSignature"])
+
+	b, err := EventDecrypt("wx2421b1c4370ec43b", testEventAESKey, encrypt)
+	assert.Nil(t, err)
+	news, err := XMLToX(b)
+	assert.Nil(t, err)
+	assert.Equal(t, "news", news["MsgType"])
+	assert.Equal(t, "1", news["ArticleCount"])
+	assert.Equal(t, "welcome", news["Articles"].(X)["item"].(X)["Title"])
+}
+
+func TestEventServerCorpURL(t *testing.T) {
+	corp := NewCorp("ww1436e0e65a779aee", "secret", WithCorpSrvCfg(testEventToken, testEventAESKey))
+
+	ct, err := EventEncrypt("ww1436e0e65a779aee", testEventAESKey, "0123456789abcdef", []byte("1288432585"))
+	assert.Nil(t, err)
+
+	query := testEventQuery(ct.String())
+	query.Set("echostr", ct.String())
+
+	w := httptest.NewRecorder()
+	corp.EventServer().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/event?"+query.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1288432585", w.Body.String())
+}
+
+func TestEventServerJSON(t *testing.T) {
+	mp := NewMiniProgram("wx2421b1c4370ec43b", "secret", WithMPSrvCfg(testEventToken, testEventAESKey))
+
+	srv := mp.EventServer()
+	srv.OnEvent(EventMediaCheck, func(ctx context.Context, msg *EventMsg) (X, error) {
+		ret, err := msg.MediaCheck()
+		assert.Nil(t, err)
+		assert.Equal(t, "wxb3d8b3b1d8a5f1b3", ret.AppID)
+		assert.Equal(t, "risky", ret.Result.Suggest)
+		assert.Equal(t, int64(20006), ret.Result.Label)
+		assert.Len(t, ret.Detail, 1)
+		return msg.ReplyTransferCustomerService(""), nil
+	})
+
+	plain := `{"ToUserName":"gh_38cc49f9733b","FromUserName":"oH1fu0FdHqpToe2T6gBj0WyB8iS1","CreateTime":1626959646,"MsgType":"event","Event":"wxa_media_check","appid":"wxb3d8b3b1d8a5f1b3","trace_id":"60f96f1d-3845297a-1976a3ae","version":2,"detail":[{"strategy":"content_model","errcode":0,"suggest":"risky","label":20006,"prob":90}],"errcode":0,"errmsg":"ok","result":{"suggest":"risky","label":20006}}`
+	ct, err := EventEncrypt("wx2421b1c4370ec43b", testEventAESKey, "0123456789abcdef", []byte(plain))
+	assert.Nil(t, err)
+
+	query := testEventQuery(ct.String())
+	query.Set("encrypt_type", "aes")
+	body := `{"ToUserName":"gh_38cc49f9733b","Encrypt":"` + ct.String() + `"}`
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/event?"+query.Encode(), strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	reply := gjson.ParseBytes(w.Body.Bytes())
+	encrypt := reply.Get("Encrypt").String()
+	assert.Equal(t, SignWithSHA1(testEventToken, reply.Get("TimeStamp").String(), reply.Get("Nonce").String(), encrypt), reply.Get("MsgSignature").String())
+
+	ret, err := mp.DecodeEventJSON(encrypt)
+	assert.Nil(t, err)
+	assert.Equal(t, MsgTransferCS, ret.Get("MsgType").String())
+	assert.Equal(t, "oH1fu0FdHqpToe2T6gBj0WyB8iS1", ret.Get("ToUserName").String())
+}
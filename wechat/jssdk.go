@@ -0,0 +1,133 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yiigo/sdk-go/internal"
+	"github.com/yiigo/sdk-go/internal/xhash"
+)
+
+// JSSDKConfig JS-SDK权限验证配置 (wx.config)
+type JSSDKConfig struct {
+	AppID     string `json:"appId"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Signature string `json:"signature"`
+}
+
+// AgentConfig 企业微信应用权限验证配置 (wx.agentConfig)
+type AgentConfig struct {
+	CorpID    string `json:"corpid"`
+	AgentID   int64  `json:"agentid"`
+	Timestamp int64  `json:"timestamp"`
+	NonceStr  string `json:"nonceStr"`
+	Signature string `json:"signature"`
+}
+
+// JSSDKSignature 生成JS-SDK签名
+//
+//	[参考](https://developers.weixin.qq.com/doc/offiaccount/OA_Web_Apps/JS-SDK.html#62)
+//	url 为当前网页的完整URL，不包含#及其后面部分
+func JSSDKSignature(ticket, nonce string, timestamp int64, url string) string {
+	if i := strings.IndexByte(url, '#'); i >= 0 {
+		url = url[:i]
+	}
+	return xhash.SHA1(fmt.Sprintf("jsapi_ticket=%s&noncestr=%s&timestamp=%d&url=%s", ticket, nonce, timestamp, url))
+}
+
+func (oa *OfficialAccount) initTicket() {
+	oa.ticket = newTicketSource(oa.token, "jsapi_ticket", func(ctx context.Context) (*Token, error) {
+		ret, err := oa.GetJSON(ctx, "/cgi-bin/ticket/getticket", url.Values{"type": []string{"jsapi"}})
+		if err != nil {
+			return nil, err
+		}
+		return NewToken(ret.Get("ticket").String(), ret.Get("expires_in").Int()), nil
+	})
+}
+
+// JSAPITicket 获取jsapi_ticket (按需获取并缓存，临近过期自动刷新)
+func (oa *OfficialAccount) JSAPITicket(ctx context.Context) (string, error) {
+	return oa.ticket.fetch(ctx)
+}
+
+// JSSDKConfig 生成网页的JS-SDK权限验证配置 (wx.config)
+func (oa *OfficialAccount) JSSDKConfig(ctx context.Context, url string) (*JSSDKConfig, error) {
+	ticket, err := oa.ticket.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &JSSDKConfig{
+		AppID:     oa.appid,
+		Timestamp: time.Now().Unix(),
+		NonceStr:  internal.Nonce(16),
+	}
+	cfg.Signature = JSSDKSignature(ticket, cfg.NonceStr, cfg.Timestamp, url)
+	return cfg, nil
+}
+
+func (c *Corp) initTicket() {
+	c.jsapiTicket = newTicketSource(c.token, "jsapi_ticket", func(ctx context.Context) (*Token, error) {
+		ret, err := c.GetJSON(ctx, "/cgi-bin/get_jsapi_ticket", nil)
+		if err != nil {
+			return nil, err
+		}
+		return NewToken(ret.Get("ticket").String(), ret.Get("expires_in").Int()), nil
+	})
+	c.agentTicket = newTicketSource(c.token, "agent_ticket", func(ctx context.Context) (*Token, error) {
+		ret, err := c.GetJSON(ctx, "/cgi-bin/ticket/get", url.Values{"type": []string{"agent_config"}})
+		if err != nil {
+			return nil, err
+		}
+		return NewToken(ret.Get("ticket").String(), ret.Get("expires_in").Int()), nil
+	})
+}
+
+// JSAPITicket 获取企业的jsapi_ticket (按需获取并缓存，临近过期自动刷新)
+func (c *Corp) JSAPITicket(ctx context.Context) (string, error) {
+	return c.jsapiTicket.fetch(ctx)
+}
+
+// AgentTicket 获取应用的jsapi_ticket (按需获取并缓存，临近过期自动刷新)
+func (c *Corp) AgentTicket(ctx context.Context) (string, error) {
+	return c.agentTicket.fetch(ctx)
+}
+
+// JSSDKConfig 生成网页的JS-SDK权限验证配置 (wx.config)
+func (c *Corp) JSSDKConfig(ctx context.Context, url string) (*JSSDKConfig, error) {
+	ticket, err := c.jsapiTicket.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &JSSDKConfig{
+		AppID:     c.corpid,
+		Timestamp: time.Now().Unix(),
+		NonceStr:  internal.Nonce(16),
+	}
+	cfg.Signature = JSSDKSignature(ticket, cfg.NonceStr, cfg.Timestamp, url)
+	return cfg, nil
+}
+
+// AgentConfig 生成网页的应用权限验证配置 (wx.agentConfig)
+//
+//	access_token 须为该应用的凭证
+func (c *Corp) AgentConfig(ctx context.Context, agentID int64, url string) (*AgentConfig, error) {
+	ticket, err := c.agentTicket.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &AgentConfig{
+		CorpID:    c.corpid,
+		AgentID:   agentID,
+		Timestamp: time.Now().Unix(),
+		NonceStr:  internal.Nonce(16),
+	}
+	cfg.Signature = JSSDKSignature(ticket, cfg.NonceStr, cfg.Timestamp, url)
+	return cfg, nil
+}
@@ -0,0 +1,49 @@
+package wechat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSSDKSignature(t *testing.T) {
+	ticket := "sM4AOVdWfPE4DxkXGEs8VMCPGGVi4C3VM0P37wVUCFvkVAy_90u5h9nbSlYy3-Sl-HhTdfl2fzFy1AOcHKP7qg"
+	sign := JSSDKSignature(ticket, "Wm3WZYTPz0wzccnW", 1414587457, "http://mp.weixin.qq.com?params=value#hash")
+	assert.Equal(t, "0f9de62fce790f9a083d5c99e95740ceb90c27ed", sign)
+}
+
+func TestCorpAgentConfig(t *testing.T) {
+	var tickets int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/ticket/get":
+			atomic.AddInt32(&tickets, 1)
+			assert.Equal(t, "agent_config", r.URL.Query().Get("type"))
+			assert.Equal(t, "ACCESS_TOKEN", r.URL.Query().Get(AccessToken))
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok","ticket":"AGENT_TICKET","expires_in":7200}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	store := NewMemTokenStore()
+	corp := NewCorp("ww1436e0e65a779aee", "secret", WithCorpTokenStore(store))
+	corp.host = srv.URL
+	assert.Nil(t, store.Set(ctx, corp.token.key, NewToken("ACCESS_TOKEN", 7200)))
+
+	for i := 0; i < 2; i++ {
+		cfg, err := corp.AgentConfig(ctx, 1000002, "https://example.com/app")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1000002), cfg.AgentID)
+		assert.Equal(t, JSSDKSignature("AGENT_TICKET", cfg.NonceStr, cfg.Timestamp, "https://example.com/app"), cfg.Signature)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tickets))
+}
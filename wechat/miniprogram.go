@@ -13,7 +13,6 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -23,15 +22,6 @@ import (
 	"github.com/yiigo/sdk-go/internal/xcrypto"
 )
 
-// SafeMode 安全鉴权模式配置
-type SafeMode struct {
-	aesSN  string
-	aeskey string
-	prvKey *xcrypto.PrivateKey
-	pubSN  string
-	pubKey *xcrypto.PublicKey
-}
-
 // MiniProgram 小程序
 type MiniProgram struct {
 	host   string
@@ -39,10 +29,14 @@ type MiniProgram struct {
 	secret string
 	srvCfg *ServerConfig
 	sfMode *SafeMode
-	token  atomic.Value
+	token  *tokenSource
 	client *resty.Client
 
+	watermarkTTL time.Duration // 加密数据水印的有效时长
+
 	logger func(ctx context.Context, err error, data map[string]string)
+
+	component *Component // 第三方平台 (第三方平台代小程序调用时)
 }
 
 // AppID 返回appid
@@ -55,6 +49,19 @@ func (mp *MiniProgram) Secret() string {
 	return mp.secret
 }
 
+// SafeMode 返回安全鉴权模式配置 (可运行时新增或下线密钥)
+func (mp *MiniProgram) SafeMode() *SafeMode {
+	return mp.sfMode
+}
+
+// receiveID 事件消息加解密的 receiveid (第三方平台代小程序调用时为第三方平台appid)
+func (mp *MiniProgram) receiveID() string {
+	if mp.component != nil {
+		return mp.component.appid
+	}
+	return mp.appid
+}
+
 func (mp *MiniProgram) url(path string, query url.Values) string {
 	var builder strings.Builder
 
@@ -104,7 +111,7 @@ func (mp *MiniProgram) do(ctx context.Context, method, path string, header http.
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
 	}
 	return resp.Body(), nil
 }
@@ -116,9 +123,10 @@ func (mp *MiniProgram) doSafe(ctx context.Context, method, path string, query ur
 	defer log.Do(ctx, mp.logger)
 
 	now := time.Now().Unix()
+	serialNO := mp.sfMode.aesSerial()
 
 	// 加密
-	params, err := mp.encrypt(log, path, query, params, now)
+	params, err := mp.encrypt(log, path, query, params, now, serialNO)
 	if err != nil {
 		log.SetError(err)
 		return nil, err
@@ -158,7 +166,7 @@ func (mp *MiniProgram) doSafe(ctx context.Context, method, path string, query ur
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
 	}
 
 	// 验签
@@ -168,7 +176,7 @@ func (mp *MiniProgram) doSafe(ctx context.Context, method, path string, query ur
 	}
 
 	// 解密
-	data, err := mp.decrypt(path, resp.Header(), resp.Body())
+	data, err := mp.decrypt(path, serialNO, resp.Header(), resp.Body())
 	if err != nil {
 		log.SetError(err)
 		return nil, err
@@ -177,9 +185,10 @@ func (mp *MiniProgram) doSafe(ctx context.Context, method, path string, query ur
 	return data, nil
 }
 
-func (mp *MiniProgram) encrypt(log *internal.ReqLog, path string, query url.Values, params X, timestamp int64) (X, error) {
-	if len(mp.sfMode.aeskey) == 0 {
-		return nil, errors.New("aes-gcm key not found (forgotten configure?)")
+func (mp *MiniProgram) encrypt(log *internal.ReqLog, path string, query url.Values, params X, timestamp int64, serialNO string) (X, error) {
+	key, err := mp.sfMode.aesKey(serialNO)
+	if err != nil {
+		return nil, err
 	}
 
 	if params == nil {
@@ -204,14 +213,8 @@ func (mp *MiniProgram) encrypt(log *internal.ReqLog, path string, query url.Valu
 
 	log.Set("origin_request_body", string(data))
 
-	key, err := base64.StdEncoding.DecodeString(mp.sfMode.aeskey)
-	if err != nil {
-		log.SetError(err)
-		return nil, err
-	}
-
 	iv := internal.NonceByte(12)
-	aad := fmt.Sprintf("%s|%s|%d|%s", mp.url(path, nil), mp.appid, timestamp, mp.sfMode.aesSN)
+	aad := fmt.Sprintf("%s|%s|%d|%s", mp.url(path, nil), mp.appid, timestamp, serialNO)
 
 	ct, err := xcrypto.AESEncryptGCM(key, iv, data, []byte(aad), nil)
 	if err != nil {
@@ -228,7 +231,8 @@ func (mp *MiniProgram) encrypt(log *internal.ReqLog, path string, query url.Valu
 }
 
 func (mp *MiniProgram) sign(path string, timestamp int64, body []byte) (string, error) {
-	if mp.sfMode.prvKey == nil {
+	prvKey := mp.sfMode.privateKey()
+	if prvKey == nil {
 		return "", errors.New("private key not found (forgotten configure?)")
 	}
 
@@ -242,7 +246,7 @@ func (mp *MiniProgram) sign(path string, timestamp int64, body []byte) (string,
 	builder.WriteString("\n")
 	builder.Write(body)
 
-	b, err := mp.sfMode.prvKey.SignPSS(crypto.SHA256, []byte(builder.String()), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	b, err := prvKey.SignPSS(crypto.SHA256, []byte(builder.String()), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
 	if err != nil {
 		return "", err
 	}
@@ -250,21 +254,19 @@ func (mp *MiniProgram) sign(path string, timestamp int64, body []byte) (string,
 }
 
 func (mp *MiniProgram) verify(path string, header http.Header, body []byte) error {
-	if mp.sfMode.pubKey == nil {
-		return errors.New("public key not found (forgotten configure?)")
-	}
-
 	if appid := header.Get(HeaderMPAppID); appid != mp.appid {
 		return fmt.Errorf("header appid mismatch, expect = %s", mp.appid)
 	}
 
+	// 平台密钥轮换期间，应答同时携带新旧两组签名，优先使用已配置的新证书
 	var sign string
-	if serial := header.Get(HeaderMPSerial); serial == mp.sfMode.pubSN {
+	pubKey := mp.sfMode.publicKey(header.Get(HeaderMPSerial))
+	if pubKey != nil {
 		sign = header.Get(HeaderMPSignature)
 	} else {
 		serialDeprecated := header.Get(HeaderMPSerialDeprecated)
-		if serialDeprecated != mp.sfMode.pubSN {
-			return fmt.Errorf("header serial mismatch, expect = %s", mp.sfMode.pubSN)
+		if pubKey = mp.sfMode.publicKey(serialDeprecated); pubKey == nil {
+			return fmt.Errorf("public key not found, serial = %s|%s", header.Get(HeaderMPSerial), serialDeprecated)
 		}
 		sign = header.Get(HeaderMPSignatureDeprecated)
 	}
@@ -283,15 +285,11 @@ func (mp *MiniProgram) verify(path string, header http.Header, body []byte) erro
 	builder.WriteString("\n")
 	builder.Write(body)
 
-	return mp.sfMode.pubKey.VerifyPSS(crypto.SHA256, []byte(builder.String()), b, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	return pubKey.VerifyPSS(crypto.SHA256, []byte(builder.String()), b, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
 }
 
-func (mp *MiniProgram) decrypt(path string, header http.Header, body []byte) ([]byte, error) {
-	if len(mp.sfMode.aeskey) == 0 {
-		return nil, errors.New("aes-gcm key not found (forgotten configure?)")
-	}
-
-	key, err := base64.StdEncoding.DecodeString(mp.sfMode.aeskey)
+func (mp *MiniProgram) decrypt(path, serialNO string, header http.Header, body []byte) ([]byte, error) {
+	key, err := mp.sfMode.aesKey(serialNO)
 	if err != nil {
 		return nil, err
 	}
@@ -313,13 +311,18 @@ func (mp *MiniProgram) decrypt(path string, header http.Header, body []byte) ([]
 		return nil, err
 	}
 
-	aad := fmt.Sprintf("%s|%s|%s|%s", mp.url(path, nil), mp.appid, header.Get(HeaderMPTimestamp), mp.sfMode.aesSN)
+	aad := fmt.Sprintf("%s|%s|%s|%s", mp.url(path, nil), mp.appid, header.Get(HeaderMPTimestamp), serialNO)
 
 	return xcrypto.AESDecryptGCM(key, iv, append(data, tag...), []byte(aad), nil)
 }
 
 // Code2Session 通过临时登录凭证code完成登录流程
 func (mp *MiniProgram) Code2Session(ctx context.Context, code string) (gjson.Result, error) {
+	// 第三方平台代小程序登录
+	if mp.component != nil {
+		return mp.component.Code2Session(ctx, mp.appid, code)
+	}
+
 	query := url.Values{}
 
 	query.Set("appid", mp.appid)
@@ -333,14 +336,18 @@ func (mp *MiniProgram) Code2Session(ctx context.Context, code string) (gjson.Res
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // AccessToken 获取接口调用凭据
 func (mp *MiniProgram) AccessToken(ctx context.Context) (gjson.Result, error) {
+	if mp.component != nil {
+		return internal.Fail(errAuthorizerSecret)
+	}
+
 	query := url.Values{}
 
 	query.Set("appid", mp.appid)
@@ -353,8 +360,8 @@ func (mp *MiniProgram) AccessToken(ctx context.Context) (gjson.Result, error) {
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
@@ -365,6 +372,10 @@ func (mp *MiniProgram) AccessToken(ctx context.Context) (gjson.Result, error) {
 //	[普通模式] access_token有效期内重复调用该接口不会更新access_token，绝大部分场景下使用该模式；
 //	[强制刷新模式] 会导致上次获取的access_token失效，并返回新的access_token
 func (mp *MiniProgram) StableAccessToken(ctx context.Context, forceRefresh bool) (gjson.Result, error) {
+	if mp.component != nil {
+		return internal.Fail(errAuthorizerSecret)
+	}
+
 	params := X{
 		"grant_type":    "client_credential",
 		"appid":         mp.appid,
@@ -381,167 +392,120 @@ func (mp *MiniProgram) StableAccessToken(ctx context.Context, forceRefresh bool)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // AutoLoadAccessToken 自动加载AccessToken(使用StableAccessToken接口)
-func (mp *MiniProgram) AutoLoadAccessToken(interval time.Duration) error {
-	ctx := context.Background()
-
-	// 初始化AccessToken
-	ret, err := mp.StableAccessToken(context.Background(), false)
-	if err != nil {
-		return err
-	}
-	mp.token.Store(ret.Get("access_token").String())
-
-	// 异步定时加载
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			_ret, _ := mp.StableAccessToken(ctx, false)
-			if token := _ret.Get("access_token").String(); len(token) != 0 {
-				mp.token.Store(token)
-			}
+//
+//	AccessToken 临近过期时刷新，多实例共享存储时仅一个实例刷新 (见 WithMPTokenStore)
+//	ctx 取消后停止加载；加载失败时会按指数退避重试，错误通过 Logger 上报
+func (mp *MiniProgram) AutoLoadAccessToken(ctx context.Context, interval time.Duration) error {
+	mp.token.load = func(ctx context.Context, force bool) (*Token, error) {
+		ret, err := mp.StableAccessToken(ctx, force)
+		if err != nil {
+			return nil, err
 		}
-	}(ctx)
-	return nil
-}
-
-// CustomAccessTokenLoad 自定义加载AccessToken
-func (mp *MiniProgram) CustomAccessTokenLoad(fn func(ctx context.Context, mp *MiniProgram) (string, error), interval time.Duration) error {
-	ctx := context.Background()
-
-	// 初始化AccessToken
-	token, err := fn(ctx, mp)
-	if err != nil {
-		return err
+		return NewToken(ret.Get("access_token").String(), ret.Get("expires_in").Int()), nil
 	}
-	mp.token.Store(token)
-
-	// 异步定时加载
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			_token, _ := fn(ctx, mp)
-			if len(token) != 0 {
-				mp.token.Store(_token)
-			}
-		}
-	}(ctx)
-
-	return nil
+	return mp.token.autoLoad(ctx, interval, internal.ErrLog("auto_load_access_token", mp.logger))
 }
 
-func (mp *MiniProgram) getToken() (string, error) {
-	v := mp.token.Load()
-	if v == nil {
-		return "", errors.New("access_token is empty (forgotten auto load?)")
-	}
-	token, ok := v.(string)
-	if !ok {
-		return "", errors.New("access_token is not a string")
+// CustomAccessTokenLoad 自定义加载AccessToken
+//
+//	AccessToken 临近过期时刷新，多实例共享存储时仅一个实例刷新 (见 WithMPTokenStore)
+//	ctx 取消后停止加载；加载失败时会按指数退避重试，错误通过 Logger 上报
+func (mp *MiniProgram) CustomAccessTokenLoad(ctx context.Context, fn func(ctx context.Context, mp *MiniProgram) (*Token, error), interval time.Duration) error {
+	mp.token.load = func(ctx context.Context, force bool) (*Token, error) {
+		return fn(ctx, mp)
 	}
-	return token, nil
+	return mp.token.autoLoad(ctx, interval, internal.ErrLog("custom_access_token_load", mp.logger))
 }
 
 // GetJSON GET请求JSON数据
 func (mp *MiniProgram) GetJSON(ctx context.Context, path string, query url.Values) (gjson.Result, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	b, err := mp.do(ctx, http.MethodGet, path, nil, query, nil)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return mp.do(ctx, http.MethodGet, path, nil, query, nil)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // GetBuffer GET请求获取buffer (如：获取媒体资源)
 func (mp *MiniProgram) GetBuffer(ctx context.Context, path string, query url.Values) ([]byte, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return nil, err
-	}
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	b, err := mp.do(ctx, http.MethodGet, path, nil, query, nil)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return mp.do(ctx, http.MethodGet, path, nil, query, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
 
 // PostJSON POST请求JSON数据
 func (mp *MiniProgram) PostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
 	header := http.Header{}
 	header.Set(internal.HeaderContentType, internal.ContentJSON)
 
-	b, err := mp.do(ctx, http.MethodPost, path, header, query, params)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return mp.do(ctx, http.MethodPost, path, header, query, params)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // PostBuffer POST请求获取buffer (如：获取二维码)
 func (mp *MiniProgram) PostBuffer(ctx context.Context, path string, params X) ([]byte, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return nil, err
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
 	header := http.Header{}
 	header.Set(internal.HeaderContentType, internal.ContentJSON)
 
-	b, err := mp.do(ctx, http.MethodPost, path, header, query, params)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return mp.do(ctx, http.MethodPost, path, header, query, params)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
@@ -551,21 +515,19 @@ func (mp *MiniProgram) PostBuffer(ctx context.Context, path string, params X) ([
 //	[安全鉴权模式](https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc/getting_started/api_signature.html)
 //	[支持的API](https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc)
 func (mp *MiniProgram) SafePostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
-	b, err := mp.doSafe(ctx, http.MethodPost, path, query, params)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return mp.doSafe(ctx, http.MethodPost, path, query, params)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
@@ -575,101 +537,123 @@ func (mp *MiniProgram) SafePostJSON(ctx context.Context, path string, params X)
 //	[安全鉴权模式](https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc/getting_started/api_signature.html)
 //	[支持的API](https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc)
 func (mp *MiniProgram) SafePostBuffer(ctx context.Context, path string, params X) ([]byte, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return nil, err
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
-	b, err := mp.doSafe(ctx, http.MethodPost, path, query, params)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return mp.doSafe(ctx, http.MethodPost, path, query, params)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
 
 // Upload 上传媒体资源
 func (mp *MiniProgram) Upload(ctx context.Context, reqPath, fieldName, filePath string, formData Form, query url.Values) (gjson.Result, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
-
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	reqURL := mp.url(reqPath, query)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
 
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, mp.logger)
+		reqURL := mp.url(reqPath, query)
 
-	resp, err := mp.client.R().
-		SetContext(ctx).
-		SetFile(fieldName, filePath).
-		SetFormData(formData).
-		Post(reqURL)
+		log := internal.NewReqLog(http.MethodPost, reqURL)
+		defer log.Do(ctx, mp.logger)
+
+		resp, err := mp.client.R().
+			SetContext(ctx).
+			SetFile(fieldName, filePath).
+			SetFormData(formData).
+			Post(reqURL)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetRespHeader(resp.Header())
+		log.SetStatusCode(resp.StatusCode())
+		log.SetRespBody(string(resp.Body()))
+		if !resp.IsSuccess() {
+			return nil, &APIError{HTTPStatus: resp.StatusCode()}
+		}
+		return resp.Body(), nil
+	})
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
 
-	ret := gjson.ParseBytes(resp.Body())
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // UploadWithReader 上传媒体资源
 func (mp *MiniProgram) UploadWithReader(ctx context.Context, reqPath, fieldName, fileName string, reader io.Reader, formData Form, query url.Values) (gjson.Result, error) {
-	token, err := mp.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
-
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	reqURL := mp.url(reqPath, query)
+	// 重试时需将 reader 重置到初始位置，无法重置时返回错误
+	seeker, seekable := reader.(io.Seeker)
+	offset := int64(0)
+	if seekable {
+		n, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return internal.Fail(err)
+		}
+		offset = n
+	}
+	attempts := 0
 
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, mp.logger)
+	b, err := mp.token.do(ctx, func(token string) ([]byte, error) {
+		if attempts++; attempts > 1 {
+			if !seekable {
+				return nil, errors.New("reader is not seekable, cannot retry")
+			}
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		query.Set(AccessToken, token)
 
-	resp, err := mp.client.R().
-		SetContext(ctx).
-		SetMultipartField(fieldName, fileName, "", reader).
-		SetFormData(formData).
-		Post(reqURL)
+		reqURL := mp.url(reqPath, query)
+
+		log := internal.NewReqLog(http.MethodPost, reqURL)
+		defer log.Do(ctx, mp.logger)
+
+		resp, err := mp.client.R().
+			SetContext(ctx).
+			SetMultipartField(fieldName, fileName, "", reader).
+			SetFormData(formData).
+			Post(reqURL)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetRespHeader(resp.Header())
+		log.SetStatusCode(resp.StatusCode())
+		log.SetRespBody(string(resp.Body()))
+		if !resp.IsSuccess() {
+			return nil, &APIError{HTTPStatus: resp.StatusCode()}
+		}
+		return resp.Body(), nil
+	})
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
 
-	ret := gjson.ParseBytes(resp.Body())
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
@@ -717,7 +701,7 @@ func (mp *MiniProgram) VerifyEventMsg(signature string, items ...string) error {
 //	根据配置的数据格式，解析 XML/JSON
 //	[参考](https://developers.weixin.qq.com/miniprogram/dev/framework/server-ability/message-push.html)
 func (mp *MiniProgram) DecodeEventMsg(encrypt string) ([]byte, error) {
-	return EventDecrypt(mp.appid, mp.srvCfg.aeskey, encrypt)
+	return EventDecrypt(mp.receiveID(), mp.srvCfg.aeskey, encrypt)
 }
 
 // EncodeEventReply 事件回复加密
@@ -725,7 +709,26 @@ func (mp *MiniProgram) DecodeEventMsg(encrypt string) ([]byte, error) {
 //	根据配置的数据格式，输出 XML/JSON
 //	[参考](https://developers.weixin.qq.com/miniprogram/dev/framework/server-ability/message-push.html)
 func (mp *MiniProgram) EncodeEventReply(msg V) (V, error) {
-	return EventReply(mp.appid, mp.srvCfg.token, mp.srvCfg.aeskey, msg)
+	return EventReply(mp.receiveID(), mp.srvCfg.token, mp.srvCfg.aeskey, msg)
+}
+
+// DecodeEventJSON 事件消息解密 (数据格式为JSON)
+//
+//	使用包体内的 Encrypt 字段
+//	[参考](https://developers.weixin.qq.com/miniprogram/dev/framework/server-ability/message-push.html)
+func (mp *MiniProgram) DecodeEventJSON(encrypt string) (gjson.Result, error) {
+	b, err := EventDecrypt(mp.receiveID(), mp.srvCfg.aeskey, encrypt)
+	if err != nil {
+		return internal.Fail(err)
+	}
+	return gjson.ParseBytes(b), nil
+}
+
+// EncodeEventReplyJSON 事件回复加密 (数据格式为JSON)
+//
+//	[参考](https://developers.weixin.qq.com/miniprogram/dev/framework/server-ability/message-push.html)
+func (mp *MiniProgram) EncodeEventReplyJSON(msg X) (X, error) {
+	return EventReplyJSON(mp.receiveID(), mp.srvCfg.token, mp.srvCfg.aeskey, msg)
 }
 
 // MPOption 小程序设置项
@@ -755,26 +758,47 @@ func WithMPLogger(fn func(ctx context.Context, err error, data map[string]string
 	}
 }
 
-// WithMPAesKey 设置小程序 AES-GCM 加密Key
+// WithMPTokenStore 设置AccessToken存储 (默认：内存)
+//
+//	多实例部署时，使用共享存储(如：Redis)可避免各实例分别刷新导致AccessToken互相失效
+func WithMPTokenStore(store TokenStore) MPOption {
+	return func(mp *MiniProgram) {
+		mp.token.store = store
+	}
+}
+
+// WithMPTokenRetry 设置AccessToken失效(errcode：40001、40014、42001)时是否强制刷新并重试一次 (默认：开启)
+func WithMPTokenRetry(enable bool) MPOption {
+	return func(mp *MiniProgram) {
+		mp.token.retry = enable
+	}
+}
+
+// WithMPAesKey 设置小程序 AES-GCM 加密Key (多次设置时，首个作为请求加密的密钥，其余仅用于解密)
 func WithMPAesKey(serialNO, key string) MPOption {
 	return func(mp *MiniProgram) {
-		mp.sfMode.aesSN = serialNO
-		mp.sfMode.aeskey = key
+		mp.sfMode.AddAesKey(serialNO, key)
 	}
 }
 
 // WithMPPrivateKey 设置小程序RSA私钥
 func WithMPPrivateKey(key *xcrypto.PrivateKey) MPOption {
 	return func(mp *MiniProgram) {
-		mp.sfMode.prvKey = key
+		mp.sfMode.SetPrivateKey(key)
 	}
 }
 
-// WithMPPublicKey 设置小程序平台RSA公钥
+// WithMPPublicKey 设置小程序平台RSA公钥 (可多次设置，按编号验签)
 func WithMPPublicKey(serialNO string, key *xcrypto.PublicKey) MPOption {
 	return func(mp *MiniProgram) {
-		mp.sfMode.pubSN = serialNO
-		mp.sfMode.pubKey = key
+		mp.sfMode.AddPublicKey(serialNO, key)
+	}
+}
+
+// WithMPWatermarkTTL 设置加密数据(如：手机号、用户信息)水印时间戳的有效时长 (默认：5分钟；<=0 表示不校验时效)
+func WithMPWatermarkTTL(ttl time.Duration) MPOption {
+	return func(mp *MiniProgram) {
+		mp.watermarkTTL = ttl
 	}
 }
 
@@ -785,7 +809,14 @@ func NewMiniProgram(appid, secret string, options ...MPOption) *MiniProgram {
 		appid:  appid,
 		secret: secret,
 		srvCfg: new(ServerConfig),
-		client: internal.NewClient(),
+		sfMode: newSafeMode(),
+		token: &tokenSource{
+			key:   "wechat:mp:" + appid + ":access_token",
+			store: NewMemTokenStore(),
+			retry: true,
+		},
+		client:       internal.NewClient(),
+		watermarkTTL: defaultWatermarkTTL,
 	}
 	for _, f := range options {
 		f(mp)
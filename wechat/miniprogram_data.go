@@ -0,0 +1,126 @@
+package wechat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal/xhash"
+)
+
+const defaultWatermarkTTL = 5 * time.Minute
+
+// Watermark 加密数据水印
+type Watermark struct {
+	AppID     string `json:"appid"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// PhoneInfo 用户手机号 (getPhoneNumber)
+type PhoneInfo struct {
+	PhoneNumber     string    `json:"phoneNumber"`     // 用户绑定的手机号 (国外手机号会有区号)
+	PurePhoneNumber string    `json:"purePhoneNumber"` // 没有区号的手机号
+	CountryCode     string    `json:"countryCode"`     // 区号
+	Watermark       Watermark `json:"watermark"`
+}
+
+// UserInfo 用户信息 (getUserInfo)
+type UserInfo struct {
+	OpenID    string    `json:"openId"`
+	UnionID   string    `json:"unionId"`
+	NickName  string    `json:"nickName"`
+	Gender    int       `json:"gender"`
+	City      string    `json:"city"`
+	Province  string    `json:"province"`
+	Country   string    `json:"country"`
+	AvatarURL string    `json:"avatarUrl"`
+	Language  string    `json:"language"`
+	Watermark Watermark `json:"watermark"`
+}
+
+// ShareInfo 群分享信息 (getShareInfo)
+type ShareInfo struct {
+	OpenGID   string    `json:"openGId"` // 群对当前小程序的唯一 ID
+	Watermark Watermark `json:"watermark"`
+}
+
+// RunStep 每日运动步数
+type RunStep struct {
+	Timestamp int64 `json:"timestamp"` // 时间戳，表示数据对应的时间
+	Step      int64 `json:"step"`      // 微信运动步数
+}
+
+// RunData 微信运动步数 (getWeRunData)
+type RunData struct {
+	StepInfoList []*RunStep `json:"stepInfoList"`
+	Watermark    Watermark  `json:"watermark"`
+}
+
+// CheckSignature 校验用户信息原始数据签名 (signature = sha1(rawData + session_key))
+//
+//	[参考](https://developers.weixin.qq.com/miniprogram/dev/framework/open-ability/signature.html)
+func (mp *MiniProgram) CheckSignature(sessionKey, rawData, signature string) error {
+	if xhash.SHA1(rawData+sessionKey) != signature {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// DecodePhoneNumber 解析加密的用户手机号，并校验水印
+func (mp *MiniProgram) DecodePhoneNumber(sessionKey, iv, encryptData string) (*PhoneInfo, error) {
+	ret := new(PhoneInfo)
+	if err := mp.decodeData(sessionKey, iv, encryptData, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DecodeUserInfo 解析加密的用户信息，并校验水印
+func (mp *MiniProgram) DecodeUserInfo(sessionKey, iv, encryptData string) (*UserInfo, error) {
+	ret := new(UserInfo)
+	if err := mp.decodeData(sessionKey, iv, encryptData, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DecodeShareInfo 解析加密的群分享信息，并校验水印
+func (mp *MiniProgram) DecodeShareInfo(sessionKey, iv, encryptData string) (*ShareInfo, error) {
+	ret := new(ShareInfo)
+	if err := mp.decodeData(sessionKey, iv, encryptData, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DecodeRunData 解析加密的微信运动步数，并校验水印
+func (mp *MiniProgram) DecodeRunData(sessionKey, iv, encryptData string) (*RunData, error) {
+	ret := new(RunData)
+	if err := mp.decodeData(sessionKey, iv, encryptData, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// decodeData 解密数据并校验水印 (appid 和 时效)
+func (mp *MiniProgram) decodeData(sessionKey, iv, encryptData string, v any) error {
+	b, err := mp.DecodeEncryptData(sessionKey, iv, encryptData)
+	if err != nil {
+		return err
+	}
+
+	watermark := gjson.GetBytes(b, "watermark")
+	if appid := watermark.Get("appid").String(); appid != mp.appid {
+		return fmt.Errorf("watermark appid mismatch, expect = %s, actual = %s", mp.appid, appid)
+	}
+	if mp.watermarkTTL > 0 {
+		ts := time.Unix(watermark.Get("timestamp").Int(), 0)
+		if d := time.Since(ts); d > mp.watermarkTTL || d < -mp.watermarkTTL {
+			return fmt.Errorf("watermark expired, timestamp = %d", ts.Unix())
+		}
+	}
+	return json.Unmarshal(b, v)
+}
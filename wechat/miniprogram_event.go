@@ -0,0 +1,79 @@
+package wechat
+
+import "fmt"
+
+// MediaCheckResult 内容安全综合结果
+type MediaCheckResult struct {
+	Suggest string `json:"suggest" xml:"suggest"` // risky、pass、review
+	Label   int64  `json:"label" xml:"label"`
+}
+
+// MediaCheckDetail 内容安全详细检测结果
+type MediaCheckDetail struct {
+	Strategy string `json:"strategy" xml:"strategy"`
+	ErrCode  int64  `json:"errcode" xml:"errcode"`
+	Suggest  string `json:"suggest" xml:"suggest"`
+	Label    int64  `json:"label" xml:"label"`
+	Prob     int64  `json:"prob" xml:"prob"`
+}
+
+// MediaCheckEvent 音视频内容安全识别结果 (wxa_media_check)
+//
+//	[参考](https://developers.weixin.qq.com/miniprogram/dev/OpenApiDoc/sec-center/sec-check/mediaCheckAsync.html)
+type MediaCheckEvent struct {
+	ToUserName   string              `json:"ToUserName" xml:"ToUserName"`
+	FromUserName string              `json:"FromUserName" xml:"FromUserName"`
+	CreateTime   int64               `json:"CreateTime" xml:"CreateTime"`
+	MsgType      string              `json:"MsgType" xml:"MsgType"`
+	Event        string              `json:"Event" xml:"Event"`
+	AppID        string              `json:"appid" xml:"appid"`
+	TraceID      string              `json:"trace_id" xml:"trace_id"`
+	Version      int64               `json:"version" xml:"version"`
+	Result       *MediaCheckResult   `json:"result" xml:"result"`
+	Detail       []*MediaCheckDetail `json:"detail" xml:"detail"`
+}
+
+// TradeSettlementEvent 订单完成发货及结算 (trade_manage_order_settlement)
+//
+//	[参考](https://developers.weixin.qq.com/miniprogram/dev/platform-capabilities/business-capabilities/order-shipping/order-shipping.html)
+type TradeSettlementEvent struct {
+	ToUserName              string `json:"ToUserName" xml:"ToUserName"`
+	FromUserName            string `json:"FromUserName" xml:"FromUserName"`
+	CreateTime              int64  `json:"CreateTime" xml:"CreateTime"`
+	MsgType                 string `json:"MsgType" xml:"MsgType"`
+	Event                   string `json:"Event" xml:"Event"`
+	TransactionID           string `json:"transaction_id" xml:"transaction_id"`
+	MerchantID              string `json:"merchant_id" xml:"merchant_id"`
+	SubMerchantID           string `json:"sub_merchant_id" xml:"sub_merchant_id"`
+	MerchantTradeNo         string `json:"merchant_trade_no" xml:"merchant_trade_no"`
+	PayTime                 int64  `json:"pay_time" xml:"pay_time"`
+	ShippedTime             int64  `json:"shipped_time" xml:"shipped_time"`
+	EstimatedSettlementTime int64  `json:"estimated_settlement_time" xml:"estimated_settlement_time"`
+	ConfirmReceiveMethod    int64  `json:"confirm_receive_method" xml:"confirm_receive_method"` // 1-手动确认收货，2-自动确认收货
+	ConfirmReceiveTime      int64  `json:"confirm_receive_time" xml:"confirm_receive_time"`
+	SettlementTime          int64  `json:"settlement_time" xml:"settlement_time"`
+}
+
+// MediaCheck 解析音视频内容安全识别结果
+func (m *EventMsg) MediaCheck() (*MediaCheckEvent, error) {
+	if m.Event != EventMediaCheck {
+		return nil, fmt.Errorf("event mismatch, expect = %s, actual = %s", EventMediaCheck, m.Event)
+	}
+	ret := new(MediaCheckEvent)
+	if err := m.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TradeSettlement 解析订单完成发货及结算事件
+func (m *EventMsg) TradeSettlement() (*TradeSettlementEvent, error) {
+	if m.Event != EventTradeSettlement {
+		return nil, fmt.Errorf("event mismatch, expect = %s, actual = %s", EventTradeSettlement, m.Event)
+	}
+	ret := new(TradeSettlementEvent)
+	if err := m.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,126 @@
+package wechat
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+// SafeMode 安全鉴权模式配置
+//
+//	对称密钥和平台公钥均按编号保存，支持运行时新增和下线，便于平台密钥轮换时平滑切换
+type SafeMode struct {
+	aesSN   string            // 当前用于请求加密的对称密钥编号
+	aesKeys map[string]string // 对称密钥编号 -> 密钥(base64)
+	prvKey  *xcrypto.PrivateKey
+	pubKeys map[string]*xcrypto.PublicKey // 平台公钥编号 -> 公钥
+	mutex   sync.RWMutex
+}
+
+func newSafeMode() *SafeMode {
+	return &SafeMode{
+		aesKeys: make(map[string]string),
+		pubKeys: make(map[string]*xcrypto.PublicKey),
+	}
+}
+
+// AddAesKey 新增对称密钥 (key 为 base64 编码)
+//
+//	若当前未设置对称密钥，则作为请求加密的密钥
+func (sm *SafeMode) AddAesKey(serialNO, key string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.aesKeys[serialNO] = key
+	if len(sm.aesSN) == 0 {
+		sm.aesSN = serialNO
+	}
+}
+
+// UseAesKey 切换请求加密使用的对称密钥
+func (sm *SafeMode) UseAesKey(serialNO string) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if _, ok := sm.aesKeys[serialNO]; !ok {
+		return fmt.Errorf("aes-gcm key(serial = %s) not found", serialNO)
+	}
+	sm.aesSN = serialNO
+	return nil
+}
+
+// RetireAesKey 下线对称密钥 (不可下线当前请求加密使用的密钥，需先切换)
+func (sm *SafeMode) RetireAesKey(serialNO string) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if serialNO == sm.aesSN {
+		return fmt.Errorf("aes-gcm key(serial = %s) in use", serialNO)
+	}
+	delete(sm.aesKeys, serialNO)
+	return nil
+}
+
+// SetPrivateKey 设置RSA私钥
+func (sm *SafeMode) SetPrivateKey(key *xcrypto.PrivateKey) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.prvKey = key
+}
+
+// AddPublicKey 新增平台RSA公钥
+func (sm *SafeMode) AddPublicKey(serialNO string, key *xcrypto.PublicKey) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.pubKeys[serialNO] = key
+}
+
+// RetirePublicKey 下线平台RSA公钥
+func (sm *SafeMode) RetirePublicKey(serialNO string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	delete(sm.pubKeys, serialNO)
+}
+
+// aesSerial 返回请求加密使用的对称密钥编号
+func (sm *SafeMode) aesSerial() string {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.aesSN
+}
+
+// aesKey 根据编号返回对称密钥
+func (sm *SafeMode) aesKey(serialNO string) ([]byte, error) {
+	sm.mutex.RLock()
+	key, ok := sm.aesKeys[serialNO]
+	sm.mutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("aes-gcm key(serial = %s) not found (forgotten configure?)", serialNO)
+	}
+	return base64.StdEncoding.DecodeString(key)
+}
+
+func (sm *SafeMode) privateKey() *xcrypto.PrivateKey {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.prvKey
+}
+
+// publicKey 根据编号返回平台公钥
+func (sm *SafeMode) publicKey(serialNO string) *xcrypto.PublicKey {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	if len(serialNO) == 0 {
+		return nil
+	}
+	return sm.pubKeys[serialNO]
+}
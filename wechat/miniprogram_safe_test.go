@@ -0,0 +1,95 @@
+package wechat
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal"
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+func testRSAKeyPair(t *testing.T) (*xcrypto.PrivateKey, *xcrypto.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	prvKey, err := xcrypto.NewPrivateKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	assert.Nil(t, err)
+	pubKey, err := xcrypto.NewPublicKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}))
+	assert.Nil(t, err)
+	return prvKey, pubKey
+}
+
+func TestMPSafeModeKeyRotation(t *testing.T) {
+	oldPrv, oldPub := testRSAKeyPair(t)
+	newPrv, newPub := testRSAKeyPair(t)
+
+	aesKey1 := base64.StdEncoding.EncodeToString(internal.NonceByte(32))
+	aesKey2 := base64.StdEncoding.EncodeToString(internal.NonceByte(32))
+
+	mp := NewMiniProgram("wxba5fad812f8e6fb9", "secret", WithMPAesKey("SN_AES_1", aesKey1), WithMPPublicKey("SN_OLD", oldPub))
+	mp.SafeMode().AddAesKey("SN_AES_2", aesKey2)
+
+	path := "/wxa/getuserriskrank"
+	plain, _ := json.Marshal(X{"errcode": 0, "risk_rank": 1})
+
+	// 平台使用编号为 SN_AES_2 的对称密钥加密应答
+	key, _ := base64.StdEncoding.DecodeString(aesKey2)
+	iv := internal.NonceByte(12)
+	ct, err := xcrypto.AESEncryptGCM(key, iv, plain, []byte(fmt.Sprintf("%s|%s|%s|%s", mp.url(path, nil), mp.appid, "1635927956", "SN_AES_2")), nil)
+	assert.Nil(t, err)
+	body, _ := json.Marshal(X{
+		"iv":      base64.StdEncoding.EncodeToString(iv),
+		"data":    base64.StdEncoding.EncodeToString(ct.Data()),
+		"authtag": base64.StdEncoding.EncodeToString(ct.Tag()),
+	})
+
+	// 平台密钥轮换期间，应答同时携带新旧证书签名
+	signStr := mp.url(path, nil) + "\n" + mp.appid + "\n1635927956\n" + string(body)
+	sign := func(key *xcrypto.PrivateKey) string {
+		b, err := key.SignPSS(crypto.SHA256, []byte(signStr), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		assert.Nil(t, err)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+
+	header := http.Header{}
+	header.Set(HeaderMPAppID, mp.appid)
+	header.Set(HeaderMPTimestamp, "1635927956")
+	header.Set(HeaderMPSerial, "SN_NEW")
+	header.Set(HeaderMPSignature, sign(newPrv))
+	header.Set(HeaderMPSerialDeprecated, "SN_OLD")
+	header.Set(HeaderMPSignatureDeprecated, sign(oldPrv))
+
+	// 仅配置旧证书：使用 Deprecated 签名验签
+	assert.Nil(t, mp.verify(path, header, body))
+
+	// 新增新证书并下线旧证书
+	mp.SafeMode().AddPublicKey("SN_NEW", newPub)
+	mp.SafeMode().RetirePublicKey("SN_OLD")
+	assert.Nil(t, mp.verify(path, header, body))
+
+	header.Set(HeaderMPSerial, "SN_OTHER")
+	assert.NotNil(t, mp.verify(path, header, body))
+
+	// 按编号选择对称密钥解密
+	_, err = mp.decrypt(path, "SN_AES_1", header, body)
+	assert.NotNil(t, err)
+	data, err := mp.decrypt(path, "SN_AES_2", header, body)
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(plain), string(data))
+
+	// 当前加密密钥不可下线
+	assert.NotNil(t, mp.SafeMode().RetireAesKey("SN_AES_1"))
+	assert.Nil(t, mp.SafeMode().UseAesKey("SN_AES_2"))
+	assert.Nil(t, mp.SafeMode().RetireAesKey("SN_AES_1"))
+	assert.Equal(t, "SN_AES_2", mp.sfMode.aesSerial())
+}
@@ -1,10 +1,15 @@
 package wechat
 
 import (
+	"encoding/base64"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal"
+	"github.com/yiigo/sdk-go/internal/xcrypto"
 )
 
 // AppId = "wxba5fad812f8e6fb9"
@@ -36,3 +41,50 @@ func Test_Minip_DecodeEventMsg(t *testing.T) {
 	assert.Nil(t, err)
 	fmt.Println(string(b))
 }
+
+func testMPEncryptData(t *testing.T, key, iv []byte, plain string) string {
+	ct, err := xcrypto.AESEncryptCBC(key, iv, []byte(plain))
+	assert.Nil(t, err)
+	return base64.StdEncoding.EncodeToString(ct.Bytes())
+}
+
+func Test_Minip_DecodePhoneNumber(t *testing.T) {
+	minip := NewMiniProgram("wxba5fad812f8e6fb9", "secret")
+
+	key := internal.NonceByte(16)
+	iv := internal.NonceByte(16)
+	sessionKey := base64.StdEncoding.EncodeToString(key)
+	ivStr := base64.StdEncoding.EncodeToString(iv)
+
+	now := time.Now().Unix()
+
+	data := testMPEncryptData(t, key, iv, fmt.Sprintf(`{"phoneNumber":"+86 13580006666","purePhoneNumber":"13580006666","countryCode":"86","watermark":{"appid":"wxba5fad812f8e6fb9","timestamp":%d}}`, now))
+	ret, err := minip.DecodePhoneNumber(sessionKey, ivStr, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "13580006666", ret.PurePhoneNumber)
+	assert.Equal(t, now, ret.Watermark.Timestamp)
+
+	// appid 不匹配
+	data = testMPEncryptData(t, key, iv, fmt.Sprintf(`{"openGId":"OPENGID","watermark":{"appid":"wx0000000000000000","timestamp":%d}}`, now))
+	_, err = minip.DecodeShareInfo(sessionKey, ivStr, data)
+	assert.NotNil(t, err)
+
+	// 水印过期
+	data = testMPEncryptData(t, key, iv, fmt.Sprintf(`{"stepInfoList":[{"timestamp":1445866601,"step":100}],"watermark":{"appid":"wxba5fad812f8e6fb9","timestamp":%d}}`, now-3600))
+	_, err = minip.DecodeRunData(sessionKey, ivStr, data)
+	assert.NotNil(t, err)
+
+	run, err := NewMiniProgram("wxba5fad812f8e6fb9", "secret", WithMPWatermarkTTL(0)).DecodeRunData(sessionKey, ivStr, data)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), run.StepInfoList[0].Step)
+}
+
+func Test_Minip_CheckSignature(t *testing.T) {
+	minip := NewMiniProgram("wxba5fad812f8e6fb9", "secret")
+
+	rawData := `{"nickName":"Band","gender":1,"language":"zh_CN","city":"Guangzhou","province":"Guangdong","country":"CN","avatarUrl":"http://wx.qlogo.cn/mmopen/vi_32/1vZvI39NWFQ9XM4LtQpFrQJ1xlgZxx3w7bQxKARol6503Iuswjjn6nIGBiaycAjAtpujxyzYsrztuuICqIM5ibXQ/0"}`
+	sessionKey := "HyVFkGl5F5OQWJZZaNzBBg=="
+
+	assert.Nil(t, minip.CheckSignature(sessionKey, rawData, "75e81ceda165f4ffa64f4068af58c64b8f54b88c"))
+	assert.NotNil(t, minip.CheckSignature(sessionKey, rawData+" ", "75e81ceda165f4ffa64f4068af58c64b8f54b88c"))
+}
@@ -9,7 +9,6 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -30,9 +29,12 @@ type OfficialAccount struct {
 	appid  string
 	secret string
 	srvCfg *ServerConfig
-	token  atomic.Value
+	token  *tokenSource
+	ticket *tokenSource // jsapi_ticket
 	client *resty.Client
 	logger func(ctx context.Context, err error, data map[string]string)
+
+	component *Component // 第三方平台 (第三方平台代公众号调用时)
 }
 
 // AppID returns appid
@@ -45,6 +47,14 @@ func (oa *OfficialAccount) Secret() string {
 	return oa.secret
 }
 
+// receiveID 事件消息加解密的 receiveid (第三方平台代公众号调用时为第三方平台appid)
+func (oa *OfficialAccount) receiveID() string {
+	if oa.component != nil {
+		return oa.component.appid
+	}
+	return oa.appid
+}
+
 // URL 生成请求URL
 func (oa *OfficialAccount) url(path string, query url.Values) string {
 	var builder strings.Builder
@@ -95,7 +105,7 @@ func (oa *OfficialAccount) do(ctx context.Context, method, path string, header h
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
 	}
 	return resp.Body(), nil
 }
@@ -132,6 +142,11 @@ func (oa *OfficialAccount) SubscribeMsgAuthURL(scene, templateID, redirectURL, r
 
 // Code2OAuthToken 获取网页授权Token
 func (oa *OfficialAccount) Code2OAuthToken(ctx context.Context, code string) (gjson.Result, error) {
+	// 第三方平台代公众号网页授权
+	if oa.component != nil {
+		return oa.component.Code2OAuthToken(ctx, oa.appid, code)
+	}
+
 	query := url.Values{}
 
 	query.Set("appid", oa.appid)
@@ -145,14 +160,18 @@ func (oa *OfficialAccount) Code2OAuthToken(ctx context.Context, code string) (gj
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // RefreshOAuthToken 刷新网页授权Token
 func (oa *OfficialAccount) RefreshOAuthToken(ctx context.Context, refreshToken string) (gjson.Result, error) {
+	if oa.component != nil {
+		return oa.component.RefreshOAuthToken(ctx, oa.appid, refreshToken)
+	}
+
 	query := url.Values{}
 
 	query.Set("appid", oa.appid)
@@ -165,14 +184,18 @@ func (oa *OfficialAccount) RefreshOAuthToken(ctx context.Context, refreshToken s
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // AccessToken 获取接口调用凭据
 func (oa *OfficialAccount) AccessToken(ctx context.Context) (gjson.Result, error) {
+	if oa.component != nil {
+		return internal.Fail(errAuthorizerSecret)
+	}
+
 	query := url.Values{}
 
 	query.Set("appid", oa.appid)
@@ -185,8 +208,8 @@ func (oa *OfficialAccount) AccessToken(ctx context.Context) (gjson.Result, error
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
@@ -197,6 +220,10 @@ func (oa *OfficialAccount) AccessToken(ctx context.Context) (gjson.Result, error
 //	[普通模式] access_token 有效期内重复调用该接口不会更新 access_token，绝大部分场景下使用该模式；
 //	[强制刷新模式] 会导致上次获取的 access_token 失效，并返回新的 access_token
 func (oa *OfficialAccount) StableAccessToken(ctx context.Context, forceRefresh bool) (gjson.Result, error) {
+	if oa.component != nil {
+		return internal.Fail(errAuthorizerSecret)
+	}
+
 	params := X{
 		"grant_type":    "client_credential",
 		"appid":         oa.appid,
@@ -213,246 +240,224 @@ func (oa *OfficialAccount) StableAccessToken(ctx context.Context, forceRefresh b
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // AutoLoadAccessToken 自动加载AccessToken(使用StableAccessToken接口)
-func (oa *OfficialAccount) AutoLoadAccessToken(interval time.Duration) error {
-	ctx := context.Background()
-
-	// 初始化AccessToken
-	ret, err := oa.StableAccessToken(ctx, false)
-	if err != nil {
-		return err
-	}
-	oa.token.Store(ret.Get("access_token").String())
-
-	// 异步定时加载
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for range ticker.C {
-			_ret, _ := oa.StableAccessToken(ctx, false)
-			if token := _ret.Get("access_token").String(); len(token) != 0 {
-				oa.token.Store(token)
-			}
+//
+//	AccessToken 临近过期时刷新，多实例共享存储时仅一个实例刷新 (见 WithOATokenStore)
+//	ctx 取消后停止加载；加载失败时会按指数退避重试，错误通过 Logger 上报
+func (oa *OfficialAccount) AutoLoadAccessToken(ctx context.Context, interval time.Duration) error {
+	oa.token.load = func(ctx context.Context, force bool) (*Token, error) {
+		ret, err := oa.StableAccessToken(ctx, force)
+		if err != nil {
+			return nil, err
 		}
-	}(ctx)
-
-	return nil
+		return NewToken(ret.Get("access_token").String(), ret.Get("expires_in").Int()), nil
+	}
+	return oa.token.autoLoad(ctx, interval, internal.ErrLog("auto_load_access_token", oa.logger))
 }
 
 // CustomAccessTokenLoad 自定义加载AccessToken
-func (oa *OfficialAccount) CustomAccessTokenLoad(fn func(ctx context.Context, oa *OfficialAccount) (string, error), interval time.Duration) error {
-	ctx := context.Background()
-
-	// 初始化AccessToken
-	token, err := fn(ctx, oa)
-	if err != nil {
-		return err
-	}
-	oa.token.Store(token)
-
-	// 异步定时加载
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
-		for range ticker.C {
-			_token, _ := fn(ctx, oa)
-			if len(token) != 0 {
-				oa.token.Store(_token)
-			}
-		}
-	}(ctx)
-
-	return nil
-}
-
-func (oa *OfficialAccount) getToken() (string, error) {
-	v := oa.token.Load()
-	if v == nil {
-		return "", errors.New("access_token is empty (forgotten auto load?)")
-	}
-	token, ok := v.(string)
-	if !ok {
-		return "", errors.New("access_token is not a string")
+//
+//	AccessToken 临近过期时刷新，多实例共享存储时仅一个实例刷新 (见 WithOATokenStore)
+//	ctx 取消后停止加载；加载失败时会按指数退避重试，错误通过 Logger 上报
+func (oa *OfficialAccount) CustomAccessTokenLoad(ctx context.Context, fn func(ctx context.Context, oa *OfficialAccount) (*Token, error), interval time.Duration) error {
+	oa.token.load = func(ctx context.Context, force bool) (*Token, error) {
+		return fn(ctx, oa)
 	}
-	return token, nil
+	return oa.token.autoLoad(ctx, interval, internal.ErrLog("custom_access_token_load", oa.logger))
 }
 
 // GetJSON GET请求JSON数据
 func (oa *OfficialAccount) GetJSON(ctx context.Context, path string, query url.Values) (gjson.Result, error) {
-	token, err := oa.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	b, err := oa.do(ctx, http.MethodGet, path, nil, query, nil)
+	b, err := oa.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return oa.do(ctx, http.MethodGet, path, nil, query, nil)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // PostJSON POST请求JSON数据
 func (oa *OfficialAccount) PostJSON(ctx context.Context, path string, params X) (gjson.Result, error) {
-	token, err := oa.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
 	header := http.Header{}
 	header.Set(internal.HeaderContentType, internal.ContentJSON)
 
-	b, err := oa.do(ctx, http.MethodPost, path, header, query, params)
+	b, err := oa.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return oa.do(ctx, http.MethodPost, path, header, query, params)
+	})
 	if err != nil {
 		return internal.Fail(err)
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // GetBuffer GET请求获取buffer (如：获取媒体资源)
 func (oa *OfficialAccount) GetBuffer(ctx context.Context, path string, query url.Values) ([]byte, error) {
-	token, err := oa.getToken()
-	if err != nil {
-		return nil, err
-	}
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	b, err := oa.do(ctx, http.MethodGet, path, nil, query, nil)
+	b, err := oa.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return oa.do(ctx, http.MethodGet, path, nil, query, nil)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
 
 // PostBuffer POST请求获取buffer (如：获取二维码)
 func (oa *OfficialAccount) PostBuffer(ctx context.Context, path string, params X) ([]byte, error) {
-	token, err := oa.getToken()
-	if err != nil {
-		return nil, err
-	}
 	query := url.Values{}
-	query.Set(AccessToken, token)
 
 	header := http.Header{}
 	header.Set(internal.HeaderContentType, internal.ContentJSON)
 
-	b, err := oa.do(ctx, http.MethodPost, path, header, query, params)
+	b, err := oa.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
+		return oa.do(ctx, http.MethodPost, path, header, query, params)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	ret := gjson.ParseBytes(b)
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return nil, fmt.Errorf("%d | %s", code, ret.Get("errmsg").String())
+	if ret.Get("errcode").Int() != 0 {
+		return nil, newAPIError(ret)
 	}
 	return b, nil
 }
 
 // Upload 上传媒体资源
 func (oa *OfficialAccount) Upload(ctx context.Context, reqPath, fieldName, filePath string, formData Form, query url.Values) (gjson.Result, error) {
-	token, err := oa.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
-
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	reqURL := oa.url(reqPath, query)
+	b, err := oa.token.do(ctx, func(token string) ([]byte, error) {
+		query.Set(AccessToken, token)
 
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, oa.logger)
+		reqURL := oa.url(reqPath, query)
 
-	resp, err := oa.client.R().
-		SetContext(ctx).
-		SetFile(fieldName, filePath).
-		SetFormData(formData).
-		Post(reqURL)
+		log := internal.NewReqLog(http.MethodPost, reqURL)
+		defer log.Do(ctx, oa.logger)
+
+		resp, err := oa.client.R().
+			SetContext(ctx).
+			SetFile(fieldName, filePath).
+			SetFormData(formData).
+			Post(reqURL)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetRespHeader(resp.Header())
+		log.SetStatusCode(resp.StatusCode())
+		log.SetRespBody(string(resp.Body()))
+		if !resp.IsSuccess() {
+			return nil, &APIError{HTTPStatus: resp.StatusCode()}
+		}
+		return resp.Body(), nil
+	})
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
 
-	ret := gjson.ParseBytes(resp.Body())
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
 
 // UploadWithReader 上传媒体资源
 func (oa *OfficialAccount) UploadWithReader(ctx context.Context, reqPath, fieldName, fileName string, reader io.Reader, formData Form, query url.Values) (gjson.Result, error) {
-	token, err := oa.getToken()
-	if err != nil {
-		return internal.Fail(err)
-	}
-
 	if query == nil {
 		query = url.Values{}
 	}
-	query.Set(AccessToken, token)
 
-	reqURL := oa.url(reqPath, query)
+	// 重试时需将 reader 重置到初始位置，无法重置时返回错误
+	seeker, seekable := reader.(io.Seeker)
+	offset := int64(0)
+	if seekable {
+		n, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return internal.Fail(err)
+		}
+		offset = n
+	}
+	attempts := 0
 
-	log := internal.NewReqLog(http.MethodPost, reqURL)
-	defer log.Do(ctx, oa.logger)
+	b, err := oa.token.do(ctx, func(token string) ([]byte, error) {
+		if attempts++; attempts > 1 {
+			if !seekable {
+				return nil, errors.New("reader is not seekable, cannot retry")
+			}
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		query.Set(AccessToken, token)
 
-	resp, err := oa.client.R().
-		SetContext(ctx).
-		SetMultipartField(fieldName, fileName, "", reader).
-		SetFormData(formData).
-		Post(reqURL)
+		reqURL := oa.url(reqPath, query)
+
+		log := internal.NewReqLog(http.MethodPost, reqURL)
+		defer log.Do(ctx, oa.logger)
+
+		resp, err := oa.client.R().
+			SetContext(ctx).
+			SetMultipartField(fieldName, fileName, "", reader).
+			SetFormData(formData).
+			Post(reqURL)
+		if err != nil {
+			log.SetError(err)
+			return nil, err
+		}
+		log.SetRespHeader(resp.Header())
+		log.SetStatusCode(resp.StatusCode())
+		log.SetRespBody(string(resp.Body()))
+		if !resp.IsSuccess() {
+			return nil, &APIError{HTTPStatus: resp.StatusCode()}
+		}
+		return resp.Body(), nil
+	})
 	if err != nil {
-		log.SetError(err)
 		return internal.Fail(err)
 	}
-	log.SetRespHeader(resp.Header())
-	log.SetStatusCode(resp.StatusCode())
-	log.SetRespBody(string(resp.Body()))
-	if !resp.IsSuccess() {
-		return internal.Fail(fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode()))
-	}
 
-	ret := gjson.ParseBytes(resp.Body())
-	if code := ret.Get("errcode").Int(); code != 0 {
-		return internal.Fail(fmt.Errorf("%d | %s", code, ret.Get("errmsg").String()))
+	ret := gjson.ParseBytes(b)
+	if ret.Get("errcode").Int() != 0 {
+		return internal.Fail(newAPIError(ret))
 	}
 	return ret, nil
 }
@@ -481,7 +486,7 @@ func (oa *OfficialAccount) VerifyEventMsg(signature string, items ...string) err
 //	根据配置的数据格式，解析 XML/JSON
 //	[参考](https://developers.weixin.qq.com/doc/offiaccount/Message_Management/Message_encryption_and_decryption_instructions.html)
 func (oa *OfficialAccount) DecodeEventMsg(encrypt string) ([]byte, error) {
-	return EventDecrypt(oa.appid, oa.srvCfg.aeskey, encrypt)
+	return EventDecrypt(oa.receiveID(), oa.srvCfg.aeskey, encrypt)
 }
 
 // EncodeEventReply 事件回复加密
@@ -489,7 +494,7 @@ func (oa *OfficialAccount) DecodeEventMsg(encrypt string) ([]byte, error) {
 //	根据配置的数据格式，输出 XML/JSON
 //	[参考](https://developers.weixin.qq.com/doc/offiaccount/Message_Management/Message_encryption_and_decryption_instructions.html)
 func (oa *OfficialAccount) EncodeEventReply(msg V) (V, error) {
-	return EventReply(oa.appid, oa.srvCfg.token, oa.srvCfg.aeskey, msg)
+	return EventReply(oa.receiveID(), oa.srvCfg.token, oa.srvCfg.aeskey, msg)
 }
 
 // OAOption 公众号设置项
@@ -519,6 +524,22 @@ func WithOALogger(fn func(ctx context.Context, err error, data map[string]string
 	}
 }
 
+// WithOATokenStore 设置AccessToken存储 (默认：内存)
+//
+//	多实例部署时，使用共享存储(如：Redis)可避免各实例分别刷新导致AccessToken互相失效
+func WithOATokenStore(store TokenStore) OAOption {
+	return func(oa *OfficialAccount) {
+		oa.token.store = store
+	}
+}
+
+// WithOATokenRetry 设置AccessToken失效(errcode：40001、40014、42001)时是否强制刷新并重试一次 (默认：开启)
+func WithOATokenRetry(enable bool) OAOption {
+	return func(oa *OfficialAccount) {
+		oa.token.retry = enable
+	}
+}
+
 // NewOfficialAccount 生成一个公众号实例
 func NewOfficialAccount(appid, secret string, options ...OAOption) *OfficialAccount {
 	oa := &OfficialAccount{
@@ -526,10 +547,16 @@ func NewOfficialAccount(appid, secret string, options ...OAOption) *OfficialAcco
 		appid:  appid,
 		secret: secret,
 		srvCfg: new(ServerConfig),
+		token: &tokenSource{
+			key:   "wechat:oa:" + appid + ":access_token",
+			store: NewMemTokenStore(),
+			retry: true,
+		},
 		client: internal.NewClient(),
 	}
 	for _, f := range options {
 		f(oa)
 	}
+	oa.initTicket()
 	return oa
 }
@@ -82,7 +82,7 @@ func (p *Pay) do(ctx context.Context, path string, params V) ([]byte, error) {
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
 	}
 	return resp.Body(), nil
 }
@@ -114,12 +114,14 @@ func (p *Pay) doTls(ctx context.Context, path string, params V) ([]byte, error)
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, &APIError{HTTPStatus: resp.StatusCode()}
 	}
 	return resp.Body(), nil
 }
 
 // PostXML POST请求XML数据 (无证书请求)
+//
+//	return_code 或 result_code 为 FAIL 时返回 *APIError；result_code 为 FAIL 时同时返回结果
 func (p *Pay) PostXML(ctx context.Context, path string, params V) (V, error) {
 	b, err := p.do(ctx, path, params)
 	if err != nil {
@@ -131,15 +133,21 @@ func (p *Pay) PostXML(ctx context.Context, path string, params V) (V, error) {
 		return nil, err
 	}
 	if code := ret.Get("return_code"); code != ResultSuccess {
-		return nil, fmt.Errorf("%s | %s", code, ret.Get("return_msg"))
+		return nil, newPayError(ret)
 	}
 	if err = p.Verify(ret); err != nil {
 		return nil, err
 	}
+	// result_code 为 FAIL 时，同时返回已验签的结果，便于根据 err_code 及其它字段处理 (如：USERPAYING)
+	if err = newPayError(ret); err != nil {
+		return ret, err
+	}
 	return ret, nil
 }
 
 // PostTLSXML POST请求XML数据 (带证书请求)
+//
+//	return_code 或 result_code 为 FAIL 时返回 *APIError；result_code 为 FAIL 时同时返回结果
 func (p *Pay) PostTLSXML(ctx context.Context, path string, params V) (V, error) {
 	b, err := p.doTls(ctx, path, params)
 	if err != nil {
@@ -151,11 +159,15 @@ func (p *Pay) PostTLSXML(ctx context.Context, path string, params V) (V, error)
 		return nil, err
 	}
 	if code := ret.Get("return_code"); code != ResultSuccess {
-		return nil, fmt.Errorf("%s | %s", code, ret.Get("return_msg"))
+		return nil, newPayError(ret)
 	}
 	if err = p.Verify(ret); err != nil {
 		return nil, err
 	}
+	// result_code 为 FAIL 时，同时返回已验签的结果，便于根据 err_code 及其它字段处理 (如：USERPAYING)
+	if err = newPayError(ret); err != nil {
+		return ret, err
+	}
 	return ret, nil
 }
 
@@ -172,7 +184,7 @@ func (p *Pay) PostBuffer(ctx context.Context, path string, params V) ([]byte, er
 	}
 	// 能解析出XML，说明发生错误
 	if len(ret) != 0 {
-		return nil, fmt.Errorf("%s | %s (error_code = %s, err_code_des = %s)", ret.Get("return_code"), ret.Get("return_msg"), ret.Get("error_code"), ret.Get("err_code_des"))
+		return nil, newPayError(ret)
 	}
 	return b, nil
 }
@@ -190,7 +202,7 @@ func (p *Pay) PostTlsBuffer(ctx context.Context, path string, params V) ([]byte,
 	}
 	// 能解析出XML，说明发生错误
 	if len(ret) != 0 {
-		return nil, fmt.Errorf("%s | %s | %s", ret.Get("return_code"), ret.Get("return_msg"), ret.Get("error_code"))
+		return nil, newPayError(ret)
 	}
 	return b, nil
 }
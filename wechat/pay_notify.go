@@ -0,0 +1,233 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/yiigo/sdk-go/internal"
+)
+
+// PayTradeNotify 支付(v2)支付结果通知
+//
+//	[参考](https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=9_7&index=8)
+type PayTradeNotify struct {
+	AppID              string
+	MchID              string
+	SubAppID           string
+	SubMchID           string
+	DeviceInfo         string
+	ResultCode         string
+	ErrCode            string
+	ErrCodeDes         string
+	OpenID             string
+	IsSubscribe        string
+	TradeType          string
+	BankType           string
+	TotalFee           int64
+	SettlementTotalFee int64
+	FeeType            string
+	CashFee            int64
+	CashFeeType        string
+	CouponFee          int64
+	TransactionID      string
+	OutTradeNo         string
+	Attach             string
+	TimeEnd            string
+	Values             V // 完整通知参数 (如：coupon_id_$n)
+}
+
+// IsSuccess 是否支付成功
+func (n *PayTradeNotify) IsSuccess() bool {
+	return n.ResultCode == ResultSuccess
+}
+
+// PayRefundNotify 支付(v2)退款结果通知 (req_info 已解密)
+//
+//	[参考](https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=9_16&index=10)
+type PayRefundNotify struct {
+	AppID               string
+	MchID               string
+	TransactionID       string
+	OutTradeNo          string
+	RefundID            string
+	OutRefundNo         string
+	TotalFee            int64
+	SettlementTotalFee  int64
+	RefundFee           int64
+	SettlementRefundFee int64
+	RefundStatus        string
+	SuccessTime         string
+	RefundRecvAccout    string
+	RefundAccount       string
+	RefundRequestSource string
+	Values              V // 解密后的 req_info
+}
+
+// IsSuccess 是否退款成功
+func (n *PayRefundNotify) IsSuccess() bool {
+	return n.RefundStatus == ResultSuccess
+}
+
+// ParseTradeNotify 解析支付结果通知 (校验 return_code、商户号及签名)
+func (p *Pay) ParseTradeNotify(r *http.Request) (*PayTradeNotify, error) {
+	v, err := p.readNotify(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.Verify(v); err != nil {
+		return nil, err
+	}
+
+	notify := &PayTradeNotify{
+		AppID:              v.Get("appid"),
+		MchID:              v.Get("mch_id"),
+		SubAppID:           v.Get("sub_appid"),
+		SubMchID:           v.Get("sub_mch_id"),
+		DeviceInfo:         v.Get("device_info"),
+		ResultCode:         v.Get("result_code"),
+		ErrCode:            v.Get("err_code"),
+		ErrCodeDes:         v.Get("err_code_des"),
+		OpenID:             v.Get("openid"),
+		IsSubscribe:        v.Get("is_subscribe"),
+		TradeType:          v.Get("trade_type"),
+		BankType:           v.Get("bank_type"),
+		TotalFee:           fee(v, "total_fee"),
+		SettlementTotalFee: fee(v, "settlement_total_fee"),
+		FeeType:            v.Get("fee_type"),
+		CashFee:            fee(v, "cash_fee"),
+		CashFeeType:        v.Get("cash_fee_type"),
+		CouponFee:          fee(v, "coupon_fee"),
+		TransactionID:      v.Get("transaction_id"),
+		OutTradeNo:         v.Get("out_trade_no"),
+		Attach:             v.Get("attach"),
+		TimeEnd:            v.Get("time_end"),
+		Values:             v,
+	}
+	return notify, nil
+}
+
+// ParseRefundNotify 解析退款结果通知 (校验 return_code、商户号，并解密 req_info)
+func (p *Pay) ParseRefundNotify(r *http.Request) (*PayRefundNotify, error) {
+	v, err := p.readNotify(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reqInfo := v.Get("req_info")
+	if len(reqInfo) == 0 {
+		return nil, errors.New("req_info is empty")
+	}
+	info, err := p.DecryptRefund(reqInfo)
+	if err != nil {
+		return nil, fmt.Errorf("req_info decrypt error: %w", err)
+	}
+
+	notify := &PayRefundNotify{
+		AppID:               v.Get("appid"),
+		MchID:               v.Get("mch_id"),
+		TransactionID:       info.Get("transaction_id"),
+		OutTradeNo:          info.Get("out_trade_no"),
+		RefundID:            info.Get("refund_id"),
+		OutRefundNo:         info.Get("out_refund_no"),
+		TotalFee:            fee(info, "total_fee"),
+		SettlementTotalFee:  fee(info, "settlement_total_fee"),
+		RefundFee:           fee(info, "refund_fee"),
+		SettlementRefundFee: fee(info, "settlement_refund_fee"),
+		RefundStatus:        info.Get("refund_status"),
+		SuccessTime:         info.Get("success_time"),
+		RefundRecvAccout:    info.Get("refund_recv_accout"),
+		RefundAccount:       info.Get("refund_account"),
+		RefundRequestSource: info.Get("refund_request_source"),
+		Values:              info,
+	}
+	return notify, nil
+}
+
+// TradeNotifyHandler 支付结果通知处理器
+//
+//	fn 返回 nil 时应答 SUCCESS，否则应答 FAIL，微信将按重试策略再次通知
+//	注意：同一通知可能多次送达，fn 需保证幂等
+func (p *Pay) TradeNotifyHandler(fn func(ctx context.Context, n *PayTradeNotify) error) http.Handler {
+	errLog := internal.ErrLog("notify", p.logger)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		notify, err := p.ParseTradeNotify(r)
+		if err == nil {
+			err = fn(ctx, notify)
+		}
+		if err != nil {
+			errLog(ctx, err)
+		}
+		WritePayReply(w, err)
+	})
+}
+
+// RefundNotifyHandler 退款结果通知处理器
+//
+//	fn 返回 nil 时应答 SUCCESS，否则应答 FAIL，微信将按重试策略再次通知
+//	注意：同一通知可能多次送达，fn 需保证幂等
+func (p *Pay) RefundNotifyHandler(fn func(ctx context.Context, n *PayRefundNotify) error) http.Handler {
+	errLog := internal.ErrLog("notify", p.logger)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		notify, err := p.ParseRefundNotify(r)
+		if err == nil {
+			err = fn(ctx, notify)
+		}
+		if err != nil {
+			errLog(ctx, err)
+		}
+		WritePayReply(w, err)
+	})
+}
+
+// WritePayReply 应答支付(v2)通知，err 为 nil 时应答 SUCCESS，否则应答 FAIL
+//
+//	err 不会回传给微信 (避免泄露内部错误信息)，需自行记录日志
+func WritePayReply(w http.ResponseWriter, err error) {
+	code, msg := ResultSuccess, "OK"
+	if err != nil {
+		code, msg = ResultFail, ResultFail
+	}
+
+	reply, _ := ValueToXML(V{
+		"return_code": code,
+		"return_msg":  msg,
+	})
+
+	w.Header().Set(internal.HeaderContentType, internal.ContentXML)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(reply))
+}
+
+func (p *Pay) readNotify(r *http.Request) (V, error) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := XMLToValue(b)
+	if err != nil {
+		return nil, err
+	}
+	if v.Get("return_code") != ResultSuccess {
+		return nil, newPayError(v)
+	}
+	if mchid := v.Get("mch_id"); mchid != p.mchid {
+		return nil, fmt.Errorf("mch_id mismatch, expect = %s, actual = %s", p.mchid, mchid)
+	}
+	return v, nil
+}
+
+func fee(v V, key string) int64 {
+	i, _ := strconv.ParseInt(v.Get(key), 10, 64)
+	return i
+}
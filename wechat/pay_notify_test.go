@@ -0,0 +1,119 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+	"github.com/yiigo/sdk-go/internal/xhash"
+)
+
+func TestTradeNotifyHandler(t *testing.T) {
+	pay := NewPay("10000100", "192006250b4c09247ec02edce69f6a2d")
+
+	v := V{
+		"return_code":    ResultSuccess,
+		"result_code":    ResultSuccess,
+		"appid":          "wx2421b1c4370ec43b",
+		"mch_id":         "10000100",
+		"nonce_str":      "5d2b6c2a8db53831f7eda20af46e531c",
+		"openid":         "oUpF8uMEb4qRXf22hE3X68TekukE",
+		"trade_type":     "JSAPI",
+		"total_fee":      "100",
+		"cash_fee":       "100",
+		"transaction_id": "1004400740201409030005092168",
+		"out_trade_no":   "1409811653",
+		"time_end":       "20140903131540",
+	}
+	v.Set("sign", pay.Sign(v))
+	body, err := ValueToXML(v)
+	assert.Nil(t, err)
+
+	var ret *PayTradeNotify
+	h := pay.TradeNotifyHandler(func(ctx context.Context, n *PayTradeNotify) error {
+		ret = n
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body)))
+	reply, err := XMLToValue(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, ResultSuccess, reply.Get("return_code"))
+	assert.True(t, ret.IsSuccess())
+	assert.Equal(t, int64(100), ret.TotalFee)
+	assert.Equal(t, "1409811653", ret.OutTradeNo)
+
+	// 签名错误
+	v.Set("sign", "INVALID")
+	body, err = ValueToXML(v)
+	assert.Nil(t, err)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body)))
+	reply, err = XMLToValue(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, ResultFail, reply.Get("return_code"))
+	assert.Equal(t, ResultFail, reply.Get("return_msg"))
+
+	// 通信失败
+	body, err = ValueToXML(V{"return_code": ResultFail, "return_msg": "签名失败"})
+	assert.Nil(t, err)
+
+	_, err = pay.ParseTradeNotify(httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body)))
+	var e *APIError
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, ResultFail, e.ErrCode)
+	assert.Equal(t, "签名失败", e.Msg)
+}
+
+func TestRefundNotifyHandler(t *testing.T) {
+	var logErr error
+	pay := NewPay("10000100", "192006250b4c09247ec02edce69f6a2d", WithPayLogger(func(ctx context.Context, err error, data map[string]string) {
+		logErr = err
+	}))
+
+	info, err := ValueToXML(V{
+		"transaction_id": "4200000001201409030005092168",
+		"out_trade_no":   "1409811653",
+		"refund_id":      "50000000382019052709732678859",
+		"out_refund_no":  "1415701182",
+		"total_fee":      "100",
+		"refund_fee":     "100",
+		"refund_status":  ResultSuccess,
+	})
+	assert.Nil(t, err)
+	ct, err := xcrypto.AESEncryptECB([]byte(xhash.MD5(pay.ApiKey())), []byte(info))
+	assert.Nil(t, err)
+
+	body, err := ValueToXML(V{
+		"return_code": ResultSuccess,
+		"appid":       "wx2421b1c4370ec43b",
+		"mch_id":      "10000100",
+		"nonce_str":   "TeqClE3i0mvn3DrK",
+		"req_info":    ct.String(),
+	})
+	assert.Nil(t, err)
+
+	h := pay.RefundNotifyHandler(func(ctx context.Context, n *PayRefundNotify) error {
+		assert.True(t, n.IsSuccess())
+		assert.Equal(t, "1415701182", n.OutRefundNo)
+		assert.Equal(t, int64(100), n.RefundFee)
+		return errors.New("db error")
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body)))
+	reply, err := XMLToValue(w.Body.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, ResultFail, reply.Get("return_code"))
+	// 错误信息仅记录日志，不回传给微信
+	assert.Equal(t, ResultFail, reply.Get("return_msg"))
+	assert.EqualError(t, logErr, "db error")
+}
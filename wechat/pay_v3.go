@@ -24,14 +24,18 @@ import (
 
 // PayV3 微信支付V3
 type PayV3 struct {
-	host   string
-	mchid  string
-	apikey string
-	prvSN  string
-	prvKey *xcrypto.PrivateKey
-	pubKey atomic.Value // map[string]*xcrypto.PublicKey
-	client *resty.Client
-	logger func(ctx context.Context, err error, data map[string]string)
+	host    string
+	mchid   string
+	apikey  string
+	prvSN   string
+	prvKey  *xcrypto.PrivateKey
+	pubKey  atomic.Value // map[string]*xcrypto.PublicKey
+	certSN  atomic.Value // string
+	platSN  string
+	platKey *xcrypto.PublicKey
+	window  time.Duration
+	client  *resty.Client
+	logger  func(ctx context.Context, err error, data map[string]string)
 }
 
 // MchID 返回mchid
@@ -61,6 +65,15 @@ func (p *PayV3) url(path string, query url.Values) string {
 }
 
 func (p *PayV3) publicKey(serialNO string) (*xcrypto.PublicKey, error) {
+	// 微信支付公钥
+	if p.platKey != nil && serialNO == p.platSN {
+		return p.platKey, nil
+	}
+	if strings.HasPrefix(serialNO, PayPublicKeyIDPrefix) {
+		return nil, fmt.Errorf("public key(id=%s) not found", serialNO)
+	}
+
+	// 平台证书
 	v := p.pubKey.Load()
 	if v == nil {
 		return nil, errors.New("public key is empty (forgotten auto load?)")
@@ -76,9 +89,7 @@ func (p *PayV3) publicKey(serialNO string) (*xcrypto.PublicKey, error) {
 	return pk, nil
 }
 
-func (p *PayV3) reloadCerts() error {
-	ctx := context.Background()
-
+func (p *PayV3) reloadCerts(ctx context.Context) error {
 	reqURL := p.url("/v3/certificates", nil)
 
 	log := internal.NewReqLog(http.MethodGet, reqURL)
@@ -104,25 +115,31 @@ func (p *PayV3) reloadCerts() error {
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 
-	if resp.StatusCode() >= 400 {
-		text := string(resp.Body())
-		log.SetError(errors.New(text))
-		return errors.New(text)
+	if !resp.IsSuccess() {
+		err = newPayV3Error(resp, resp.Body())
+		log.SetError(err)
+		return err
 	}
 
 	keyMap := make(map[string]*xcrypto.PublicKey)
 	headSerial := resp.Header().Get(HeaderPaySerial)
 
+	var (
+		certSN   string
+		expireAt time.Time
+	)
+
 	ret := gjson.GetBytes(resp.Body(), "data")
 	for _, v := range ret.Array() {
 		serialNO := v.Get("serial_no").String()
 		cert := v.Get("encrypt_certificate")
 
-		nonce := cert.Get("nonce").String()
-		data := cert.Get("ciphertext").String()
-		aad := cert.Get("associated_data").String()
-
-		block, err := xcrypto.AESDecryptGCM([]byte(p.apikey), []byte(nonce), []byte(data), []byte(aad), nil)
+		block, err := p.DecryptResource(&PayV3NotifyResource{
+			Algorithm:      cert.Get("algorithm").String(),
+			Ciphertext:     cert.Get("ciphertext").String(),
+			AssociatedData: cert.Get("associated_data").String(),
+			Nonce:          cert.Get("nonce").String(),
+		})
 		if err != nil {
 			log.SetError(err)
 			return err
@@ -134,6 +151,12 @@ func (p *PayV3) reloadCerts() error {
 		}
 		keyMap[serialNO] = key
 
+		// 取最晚过期的证书用于敏感信息加密
+		if t, _ := time.Parse(time.RFC3339, v.Get("expire_time").String()); len(certSN) == 0 || t.After(expireAt) {
+			certSN = serialNO
+			expireAt = t
+		}
+
 		// 签名验证
 		if serialNO == headSerial {
 			// 签名验证
@@ -146,7 +169,12 @@ func (p *PayV3) reloadCerts() error {
 			builder.Write(resp.Body())
 			builder.WriteString("\n")
 
-			if err = key.Verify(crypto.SHA256, []byte(builder.String()), []byte(resp.Header().Get(HeaderPaySignature))); err != nil {
+			sign, err := base64.StdEncoding.DecodeString(resp.Header().Get(HeaderPaySignature))
+			if err != nil {
+				log.SetError(err)
+				return err
+			}
+			if err = key.Verify(crypto.SHA256, []byte(builder.String()), sign); err != nil {
 				log.SetError(err)
 				return err
 			}
@@ -154,10 +182,11 @@ func (p *PayV3) reloadCerts() error {
 	}
 
 	p.pubKey.Store(keyMap)
+	p.certSN.Store(certSN)
 	return nil
 }
 
-func (p *PayV3) do(ctx context.Context, method, path string, query url.Values, params X) (*APIResult, error) {
+func (p *PayV3) do(ctx context.Context, method, path string, query url.Values, params X, header http.Header) (*APIResult, error) {
 	reqURL := p.url(path, query)
 
 	log := internal.NewReqLog(method, reqURL)
@@ -182,13 +211,12 @@ func (p *PayV3) do(ctx context.Context, method, path string, query url.Values, p
 		log.SetError(err)
 		return nil, err
 	}
-	log.Set(internal.HeaderAuthorization, authStr)
+	header.Set(internal.HeaderAuthorization, authStr)
+	log.SetReqHeader(header)
 
 	resp, err := p.client.R().
 		SetContext(ctx).
-		SetHeader(internal.HeaderAccept, internal.ContentJSON).
-		SetHeader(internal.HeaderAuthorization, authStr).
-		SetHeader(internal.HeaderContentType, internal.ContentJSON).
+		SetHeaderMultiValues(header).
 		SetBody(body).
 		Execute(method, reqURL)
 	if err != nil {
@@ -199,7 +227,7 @@ func (p *PayV3) do(ctx context.Context, method, path string, query url.Values, p
 	log.SetStatusCode(resp.StatusCode())
 	log.SetRespBody(string(resp.Body()))
 	if !resp.IsSuccess() {
-		return nil, fmt.Errorf("HTTP Request Error, StatusCode = %d", resp.StatusCode())
+		return nil, newPayV3Error(resp, resp.Body())
 	}
 
 	// 签名校验
@@ -216,30 +244,37 @@ func (p *PayV3) do(ctx context.Context, method, path string, query url.Values, p
 }
 
 // AutoLoadCerts 自动加载平台证书
-func (p *PayV3) AutoLoadCerts(interval time.Duration) error {
-	if err := p.reloadCerts(); err != nil {
+//
+//	ctx 取消后停止加载；加载失败时会按指数退避重试，错误通过 Logger 上报
+func (p *PayV3) AutoLoadCerts(ctx context.Context, interval time.Duration) error {
+	if err := p.reloadCerts(ctx); err != nil {
 		return err
 	}
 	// 异步定时加载
-	go func() {
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
+	go internal.Refresh(ctx, interval, p.reloadCerts, internal.ErrLog("auto_load_certs", p.logger))
 
-		for range ticker.C {
-			_ = p.reloadCerts()
-		}
-	}()
 	return nil
 }
 
 // GetJSON GET请求JSON数据
-func (p *PayV3) GetJSON(ctx context.Context, path string, query url.Values) (*APIResult, error) {
-	return p.do(ctx, http.MethodGet, path, query, nil)
+func (p *PayV3) GetJSON(ctx context.Context, path string, query url.Values, options ...PayV3HeaderOption) (*APIResult, error) {
+	header := http.Header{}
+	header.Set(internal.HeaderAccept, internal.ContentJSON)
+	for _, f := range options {
+		f(header)
+	}
+	return p.do(ctx, http.MethodGet, path, query, nil, header)
 }
 
 // PostJSON POST请求JSON数据
-func (p *PayV3) PostJSON(ctx context.Context, path string, params X) (*APIResult, error) {
-	return p.do(ctx, http.MethodPost, path, nil, params)
+func (p *PayV3) PostJSON(ctx context.Context, path string, params X, options ...PayV3HeaderOption) (*APIResult, error) {
+	header := http.Header{}
+	header.Set(internal.HeaderAccept, internal.ContentJSON)
+	header.Set(internal.HeaderContentType, internal.ContentJSON)
+	for _, f := range options {
+		f(header)
+	}
+	return p.do(ctx, http.MethodPost, path, nil, params, header)
 }
 
 // Upload 上传资源
@@ -329,8 +364,13 @@ func (p *PayV3) Download(ctx context.Context, downloadURL string, w io.Writer) e
 	log := internal.NewReqLog(http.MethodGet, downloadURL)
 	defer log.Do(ctx, p.logger)
 
-	// 获取 download_url
-	authStr, err := p.Authorization(http.MethodGet, downloadURL, nil, "")
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		log.SetError(err)
+		return err
+	}
+
+	authStr, err := p.Authorization(http.MethodGet, u.RequestURI(), nil, "")
 	if err != nil {
 		log.SetError(err)
 		return err
@@ -346,11 +386,23 @@ func (p *PayV3) Download(ctx context.Context, downloadURL string, w io.Writer) e
 		log.SetError(err)
 		return err
 	}
+	defer resp.RawResponse.Body.Close()
+
 	log.SetRespHeader(resp.Header())
 	log.SetStatusCode(resp.StatusCode())
+	if !resp.IsSuccess() {
+		b, _ := io.ReadAll(resp.RawResponse.Body)
+		log.SetRespBody(string(b))
+		err = newPayV3Error(resp, b)
+		log.SetError(err)
+		return err
+	}
 
-	_, err = io.Copy(w, resp.RawResponse.Body)
-	return err
+	if _, err = io.Copy(w, resp.RawResponse.Body); err != nil {
+		log.SetError(err)
+		return err
+	}
+	return nil
 }
 
 // Authorization 生成签名并返回 HTTP Authorization
@@ -394,7 +446,11 @@ func (p *PayV3) Verify(ctx context.Context, header http.Header, body []byte) err
 	nonce := header.Get(HeaderPayNonce)
 	timestamp := header.Get(HeaderPayTimestamp)
 	serial := header.Get(HeaderPaySerial)
-	sign := header.Get(HeaderPaySignature)
+
+	sign, err := base64.StdEncoding.DecodeString(header.Get(HeaderPaySignature))
+	if err != nil {
+		return err
+	}
 
 	key, err := p.publicKey(serial)
 	if err != nil {
@@ -412,11 +468,61 @@ func (p *PayV3) Verify(ctx context.Context, header http.Header, body []byte) err
 	}
 	builder.WriteString("\n")
 
-	return key.Verify(crypto.SHA256, []byte(builder.String()), []byte(sign))
+	return key.Verify(crypto.SHA256, []byte(builder.String()), sign)
+}
+
+// EncryptSensitive 使用平台公钥加密敏感信息 (RSAES-OAEP)，返回密文和对应的公钥ID/证书序列号
+//
+//	请求时需将返回的 serialNO 通过 WithPayV3Serial 设置到 Wechatpay-Serial (须与加密使用的公钥一致)
+//	[参考](https://pay.weixin.qq.com/docs/merchant/development/interface-rules/sensitive-data-encryption.html)
+func (p *PayV3) EncryptSensitive(plain string) (cipher, serialNO string, err error) {
+	serialNO = p.platSN
+	if p.platKey == nil {
+		if v := p.certSN.Load(); v != nil {
+			serialNO, _ = v.(string)
+		}
+		if len(serialNO) == 0 {
+			return "", "", errors.New("public key is empty (forgotten auto load?)")
+		}
+	}
+
+	key, err := p.publicKey(serialNO)
+	if err != nil {
+		return "", "", err
+	}
+
+	b, err := key.EncryptOAEP(crypto.SHA1, []byte(plain))
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), serialNO, nil
+}
+
+// DecryptSensitive 使用商户私钥解密敏感信息 (RSAES-OAEP)
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/development/interface-rules/sensitive-data-encryption.html)
+func (p *PayV3) DecryptSensitive(cipher string) (string, error) {
+	if p.prvKey == nil {
+		return "", errors.New("private key not found (forgotten configure?)")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(cipher)
+	if err != nil {
+		return "", fmt.Errorf("cipher base64.decode error: %w", err)
+	}
+	plain, err := p.prvKey.DecryptOAEP(crypto.SHA1, b)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
 }
 
 // APPAPI 用于APP拉起支付
 func (p *PayV3) APPAPI(appid, prepayID string) (V, error) {
+	if p.prvKey == nil {
+		return nil, errors.New("private key not found (forgotten configure?)")
+	}
+
 	nonce := internal.Nonce(32)
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 
@@ -445,13 +551,17 @@ func (p *PayV3) APPAPI(appid, prepayID string) (V, error) {
 		return nil, err
 	}
 
-	v.Set("sign", string(sign))
+	v.Set("sign", base64.StdEncoding.EncodeToString(sign))
 
 	return v, nil
 }
 
 // JSAPI 用于JS拉起支付
 func (p *PayV3) JSAPI(appid, prepayID string) (V, error) {
+	if p.prvKey == nil {
+		return nil, errors.New("private key not found (forgotten configure?)")
+	}
+
 	nonce := internal.Nonce(32)
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 
@@ -479,11 +589,21 @@ func (p *PayV3) JSAPI(appid, prepayID string) (V, error) {
 		return nil, err
 	}
 
-	v.Set("sign", string(sign))
+	v.Set("paySign", base64.StdEncoding.EncodeToString(sign))
 
 	return v, nil
 }
 
+// PayV3HeaderOption 支付(v3)请求头设置项
+type PayV3HeaderOption func(h http.Header)
+
+// WithPayV3Serial 设置请求头 Wechatpay-Serial (请求包含敏感信息加密字段时使用)
+func WithPayV3Serial(serialNO string) PayV3HeaderOption {
+	return func(h http.Header) {
+		h.Set(HeaderPaySerial, serialNO)
+	}
+}
+
 // PayV3Option 微信支付(v3)设置项
 type PayV3Option func(p *PayV3)
 
@@ -502,6 +622,17 @@ func WithPayV3PrivateKey(serialNO string, key *xcrypto.PrivateKey) PayV3Option {
 	}
 }
 
+// WithPayV3PlatformPublicKey 设置支付(v3)微信支付公钥 (公钥ID形如：PUB_KEY_ID_xxx)
+//
+//	可与平台证书同时使用，验签时根据 Wechatpay-Serial 自动选择
+//	[参考](https://pay.weixin.qq.com/docs/merchant/development/verify-signature-overview/wechatpay-pubkey.html)
+func WithPayV3PlatformPublicKey(id string, key *xcrypto.PublicKey) PayV3Option {
+	return func(p *PayV3) {
+		p.platSN = id
+		p.platKey = key
+	}
+}
+
 // WithPayV3Logger 设置支付(v3)日志记录
 func WithPayV3Logger(fn func(ctx context.Context, err error, data map[string]string)) PayV3Option {
 	return func(p *PayV3) {
@@ -509,12 +640,20 @@ func WithPayV3Logger(fn func(ctx context.Context, err error, data map[string]str
 	}
 }
 
+// WithPayV3NotifyWindow 设置支付(v3)回调通知的时间戳有效期 (默认：5分钟)
+func WithPayV3NotifyWindow(d time.Duration) PayV3Option {
+	return func(p *PayV3) {
+		p.window = d
+	}
+}
+
 // NewPayV3 生成一个微信支付(v3)实例
 func NewPayV3(mchid, apikey string, options ...PayV3Option) *PayV3 {
 	pay := &PayV3{
 		host:   "https://api.mch.weixin.qq.com",
 		mchid:  mchid,
 		apikey: apikey,
+		window: 5 * time.Minute,
 		client: internal.NewClient(),
 	}
 	for _, f := range options {
@@ -0,0 +1,269 @@
+package wechat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/yiigo/sdk-go/internal/xhash"
+)
+
+// DownloadBill 申请并下载账单，自动解压(tar_type=GZIP)并校验摘要(SHA1/SM3)
+//
+//	path 如：/v3/bill/tradebill、/v3/bill/fundflowbill、/v3/bill/sub-merchant-fundflowbill
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/bill-download/download-bill.html)
+func (p *PayV3) DownloadBill(ctx context.Context, path string, query url.Values) (*DownloadResult, error) {
+	ret, err := p.GetJSON(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadURL := ret.Body.Get("download_url").String()
+	if len(downloadURL) == 0 {
+		return nil, errors.New("download_url is empty")
+	}
+
+	var buf bytes.Buffer
+	if err = p.Download(ctx, downloadURL, &buf); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	// gzip 压缩包需先解压
+	if len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DownloadResult{
+		HashType:  ret.Body.Get("hash_type").String(),
+		HashValue: ret.Body.Get("hash_value").String(),
+		Buffer:    data,
+	}
+	if err = result.Verify(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Verify 校验资源摘要
+func (r *DownloadResult) Verify() error {
+	var h hash.Hash
+	switch strings.ToUpper(r.HashType) {
+	case "SHA1":
+		h = sha1.New()
+	case "SM3":
+		h = xhash.NewSM3()
+	default:
+		return fmt.Errorf("unsupported hash_type: %s", r.HashType)
+	}
+	h.Write(r.Buffer)
+
+	if v := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(v, r.HashValue) {
+		return fmt.Errorf("hash verify failed, expect = %s, actual = %s", r.HashValue, v)
+	}
+	return nil
+}
+
+// Bill 账单数据
+type Bill struct {
+	Header        []string   // 表头
+	Records       [][]string // 明细
+	SummaryHeader []string   // 汇总表头
+	Summary       []string   // 汇总
+}
+
+// Decode 将明细解析到v (*[]*T，T通过 `bill` tag 与表头对应)
+func (b *Bill) Decode(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("v must be a pointer to slice")
+	}
+
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return errors.New("v must be a pointer to slice of struct")
+	}
+
+	for _, record := range b.Records {
+		elem := reflect.New(elemType)
+		billDecodeRow(b.Header, record, elem.Elem())
+		if isPtr {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+	}
+	return nil
+}
+
+// DecodeSummary 将汇总解析到v (*T，T通过 `bill` tag 与汇总表头对应)
+func (b *Bill) DecodeSummary(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a pointer to struct")
+	}
+	billDecodeRow(b.SummaryHeader, b.Summary, rv.Elem())
+	return nil
+}
+
+func billDecodeRow(header, row []string, rv reflect.Value) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.Type.Kind() != reflect.String {
+			continue
+		}
+		tag := field.Tag.Get("bill")
+		if len(tag) == 0 {
+			continue
+		}
+		if j, ok := index[tag]; ok && j < len(row) {
+			rv.Field(i).SetString(row[j])
+		}
+	}
+}
+
+// ParseBill 解析账单文件 (字段以「`」开头，末尾为汇总数据)
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/bill-download/trade-bill.html)
+func ParseBill(b []byte) (*Bill, error) {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimRight(line, "\r"); len(strings.TrimSpace(line)) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("bill is empty")
+	}
+
+	bill := &Bill{
+		Header:  billSplitHeader(lines[0]),
+		Records: make([][]string, 0, len(lines)),
+	}
+
+	i := 1
+	for ; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "`") {
+			break
+		}
+		bill.Records = append(bill.Records, billSplitRecord(lines[i]))
+	}
+	// 汇总
+	if i < len(lines) {
+		bill.SummaryHeader = billSplitHeader(lines[i])
+		if i+1 < len(lines) {
+			bill.Summary = billSplitRecord(lines[i+1])
+		}
+	}
+	return bill, nil
+}
+
+func billSplitHeader(line string) []string {
+	fields := strings.Split(line, ",")
+	for i, v := range fields {
+		fields[i] = strings.TrimSpace(v)
+	}
+	return fields
+}
+
+func billSplitRecord(line string) []string {
+	// 字段值可能包含「,」，故以「,`」分割
+	return strings.Split(strings.TrimPrefix(line, "`"), ",`")
+}
+
+// TradeBillRecord 交易账单明细 (bill_type=ALL)
+type TradeBillRecord struct {
+	TradeTime          string `bill:"交易时间"`
+	AppID              string `bill:"公众账号ID"`
+	MchID              string `bill:"商户号"`
+	SubMchID           string `bill:"特约商户号"`
+	DeviceInfo         string `bill:"设备号"`
+	TransactionID      string `bill:"微信订单号"`
+	OutTradeNo         string `bill:"商户订单号"`
+	OpenID             string `bill:"用户标识"`
+	TradeType          string `bill:"交易类型"`
+	TradeState         string `bill:"交易状态"`
+	BankType           string `bill:"付款银行"`
+	Currency           string `bill:"货币种类"`
+	SettlementTotal    string `bill:"应结订单金额"`
+	CouponAmount       string `bill:"代金券金额"`
+	RefundID           string `bill:"微信退款单号"`
+	OutRefundNo        string `bill:"商户退款单号"`
+	RefundAmount       string `bill:"退款金额"`
+	CouponRefundAmount string `bill:"充值券退款金额"`
+	RefundType         string `bill:"退款类型"`
+	RefundStatus       string `bill:"退款状态"`
+	Body               string `bill:"商品名称"`
+	Attach             string `bill:"商户数据包"`
+	Fee                string `bill:"手续费"`
+	Rate               string `bill:"费率"`
+	TotalAmount        string `bill:"订单金额"`
+	ApplyRefundAmount  string `bill:"申请退款金额"`
+	RateRemark         string `bill:"费率备注"`
+}
+
+// TradeBillSummary 交易账单汇总
+type TradeBillSummary struct {
+	TotalCount         string `bill:"总交易单数"`
+	SettlementTotal    string `bill:"应结订单总金额"`
+	RefundAmount       string `bill:"退款总金额"`
+	CouponRefundAmount string `bill:"充值券退款总金额"`
+	Fee                string `bill:"手续费总金额"`
+	TotalAmount        string `bill:"订单总金额"`
+	ApplyRefundAmount  string `bill:"申请退款总金额"`
+}
+
+// FundBillRecord 资金账单明细
+type FundBillRecord struct {
+	BillingTime  string `bill:"记账时间"`
+	BizOrderNo   string `bill:"微信支付业务单号"`
+	FlowNo       string `bill:"资金流水单号"`
+	BizName      string `bill:"业务名称"`
+	BizType      string `bill:"业务类型"`
+	InOutType    string `bill:"收支类型"`
+	Amount       string `bill:"收支金额（元）"`
+	Balance      string `bill:"账户结余（元）"`
+	Applicant    string `bill:"资金变更提交申请人"`
+	Remark       string `bill:"备注"`
+	BizVoucherNo string `bill:"业务凭证号"`
+}
+
+// FundBillSummary 资金账单汇总
+type FundBillSummary struct {
+	TotalCount    string `bill:"资金流水总笔数"`
+	IncomeCount   string `bill:"收入笔数"`
+	IncomeAmount  string `bill:"收入金额"`
+	ExpenseCount  string `bill:"支出笔数"`
+	ExpenseAmount string `bill:"支出金额"`
+}
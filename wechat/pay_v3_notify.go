@@ -0,0 +1,288 @@
+package wechat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+// 支付v3回调通知类型
+const (
+	EventTransactionSuccess = "TRANSACTION.SUCCESS"   // 支付成功
+	EventRefundSuccess      = "REFUND.SUCCESS"        // 退款成功
+	EventRefundAbnormal     = "REFUND.ABNORMAL"       // 退款异常
+	EventRefundClosed       = "REFUND.CLOSED"         // 退款关闭
+	EventProfitSharingRecv  = "PROFITSHARING.RECEIVE" // 分账动账(分账)
+	EventProfitSharingBack  = "PROFITSHARING.RETURN"  // 分账动账(分账回退)
+)
+
+// PayV3NotifyResource 回调通知的加密资源数据
+type PayV3NotifyResource struct {
+	OriginalType   string `json:"original_type"`
+	Algorithm      string `json:"algorithm"`
+	Ciphertext     string `json:"ciphertext"`
+	AssociatedData string `json:"associated_data"`
+	Nonce          string `json:"nonce"`
+}
+
+// PayV3Notify 支付(v3)回调通知
+type PayV3Notify struct {
+	ID           string               `json:"id"`
+	CreateTime   string               `json:"create_time"`
+	EventType    string               `json:"event_type"`
+	ResourceType string               `json:"resource_type"`
+	Summary      string               `json:"summary"`
+	Resource     *PayV3NotifyResource `json:"resource"`
+
+	plaintext []byte
+}
+
+// Plaintext 返回解密后的资源数据
+func (n *PayV3Notify) Plaintext() []byte {
+	return n.plaintext
+}
+
+// Result 以 gjson.Result 返回解密后的资源数据
+func (n *PayV3Notify) Result() gjson.Result {
+	return gjson.ParseBytes(n.plaintext)
+}
+
+// Decode 将解密后的资源数据解析到v
+func (n *PayV3Notify) Decode(v any) error {
+	return json.Unmarshal(n.plaintext, v)
+}
+
+// Transaction 解析支付成功通知
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/payment-notice.html)
+func (n *PayV3Notify) Transaction() (*PayV3Transaction, error) {
+	if !strings.HasPrefix(n.EventType, "TRANSACTION.") {
+		return nil, fmt.Errorf("event_type mismatch, expect = TRANSACTION.*, actual = %s", n.EventType)
+	}
+	ret := new(PayV3Transaction)
+	if err := n.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Refund 解析退款结果通知
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/refund-result-notice.html)
+func (n *PayV3Notify) Refund() (*PayV3Refund, error) {
+	if !strings.HasPrefix(n.EventType, "REFUND.") {
+		return nil, fmt.Errorf("event_type mismatch, expect = REFUND.*, actual = %s", n.EventType)
+	}
+	ret := new(PayV3Refund)
+	if err := n.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ProfitSharing 解析分账动账通知
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/profit-sharing/profit-sharing-result-notice.html)
+func (n *PayV3Notify) ProfitSharing() (*PayV3ProfitSharing, error) {
+	if !strings.HasPrefix(n.EventType, "PROFITSHARING.") {
+		return nil, fmt.Errorf("event_type mismatch, expect = PROFITSHARING.*, actual = %s", n.EventType)
+	}
+	ret := new(PayV3ProfitSharing)
+	if err := n.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Complaint 解析消费者投诉通知
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/consumer-complaint/complaint-notifications/create-complaint-notifications.html)
+func (n *PayV3Notify) Complaint() (*PayV3Complaint, error) {
+	if !strings.HasPrefix(n.EventType, "COMPLAINT.") {
+		return nil, fmt.Errorf("event_type mismatch, expect = COMPLAINT.*, actual = %s", n.EventType)
+	}
+	ret := new(PayV3Complaint)
+	if err := n.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PayV3Payer 支付者
+type PayV3Payer struct {
+	OpenID string `json:"openid,omitempty"`
+}
+
+// PayV3Amount 订单金额
+type PayV3Amount struct {
+	Total         int64  `json:"total,omitempty"`
+	PayerTotal    int64  `json:"payer_total,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	PayerCurrency string `json:"payer_currency,omitempty"`
+}
+
+// PayV3SceneInfo 场景信息
+type PayV3SceneInfo struct {
+	DeviceID string `json:"device_id,omitempty"`
+}
+
+// PayV3PromotionGoods 单品优惠
+type PayV3PromotionGoods struct {
+	GoodsID        string `json:"goods_id"`
+	Quantity       int64  `json:"quantity"`
+	UnitPrice      int64  `json:"unit_price"`
+	DiscountAmount int64  `json:"discount_amount"`
+	GoodsRemark    string `json:"goods_remark"`
+}
+
+// PayV3Promotion 优惠功能
+type PayV3Promotion struct {
+	CouponID            string                 `json:"coupon_id"`
+	Name                string                 `json:"name"`
+	Scope               string                 `json:"scope"`
+	Type                string                 `json:"type"`
+	Amount              int64                  `json:"amount"`
+	StockID             string                 `json:"stock_id"`
+	WechatpayContribute int64                  `json:"wechatpay_contribute"`
+	MerchantContribute  int64                  `json:"merchant_contribute"`
+	OtherContribute     int64                  `json:"other_contribute"`
+	Currency            string                 `json:"currency"`
+	GoodsDetail         []*PayV3PromotionGoods `json:"goods_detail"`
+}
+
+// PayV3Transaction 支付订单
+type PayV3Transaction struct {
+	AppID           string            `json:"appid"`
+	MchID           string            `json:"mchid"`
+	OutTradeNo      string            `json:"out_trade_no"`
+	TransactionID   string            `json:"transaction_id"`
+	TradeType       string            `json:"trade_type"`
+	TradeState      string            `json:"trade_state"`
+	TradeStateDesc  string            `json:"trade_state_desc"`
+	BankType        string            `json:"bank_type"`
+	Attach          string            `json:"attach"`
+	SuccessTime     string            `json:"success_time"`
+	Payer           *PayV3Payer       `json:"payer"`
+	Amount          *PayV3Amount      `json:"amount"`
+	SceneInfo       *PayV3SceneInfo   `json:"scene_info"`
+	PromotionDetail []*PayV3Promotion `json:"promotion_detail"`
+}
+
+// PayV3RefundAmount 退款金额
+type PayV3RefundAmount struct {
+	Total            int64  `json:"total"`
+	Refund           int64  `json:"refund"`
+	PayerTotal       int64  `json:"payer_total"`
+	PayerRefund      int64  `json:"payer_refund"`
+	SettlementTotal  int64  `json:"settlement_total"`
+	SettlementRefund int64  `json:"settlement_refund"`
+	DiscountRefund   int64  `json:"discount_refund"`
+	RefundFee        int64  `json:"refund_fee"`
+	Currency         string `json:"currency"`
+}
+
+// PayV3Refund 退款单
+type PayV3Refund struct {
+	MchID               string             `json:"mchid"`
+	SpMchID             string             `json:"sp_mchid"`  // 服务商模式
+	SubMchID            string             `json:"sub_mchid"` // 服务商模式
+	TransactionID       string             `json:"transaction_id"`
+	OutTradeNo          string             `json:"out_trade_no"`
+	RefundID            string             `json:"refund_id"`
+	OutRefundNo         string             `json:"out_refund_no"`
+	RefundStatus        string             `json:"refund_status"` // 退款通知
+	Status              string             `json:"status"`        // 退款申请/查询
+	Channel             string             `json:"channel"`
+	FundsAccount        string             `json:"funds_account"`
+	CreateTime          string             `json:"create_time"`
+	SuccessTime         string             `json:"success_time"`
+	UserReceivedAccount string             `json:"user_received_account"`
+	Amount              *PayV3RefundAmount `json:"amount"`
+}
+
+// PayV3ProfitSharingReceiver 分账接收方
+type PayV3ProfitSharingReceiver struct {
+	Type        string `json:"type"`
+	Account     string `json:"account"`
+	Amount      int64  `json:"amount"`
+	Description string `json:"description"`
+}
+
+// PayV3ProfitSharing 分账动账
+type PayV3ProfitSharing struct {
+	MchID         string                      `json:"mchid"`
+	SpMchID       string                      `json:"sp_mchid"`
+	SubMchID      string                      `json:"sub_mchid"`
+	TransactionID string                      `json:"transaction_id"`
+	OrderID       string                      `json:"order_id"`
+	OutOrderNo    string                      `json:"out_order_no"`
+	Receiver      *PayV3ProfitSharingReceiver `json:"receiver"`
+	SuccessTime   string                      `json:"success_time"`
+}
+
+// PayV3Complaint 消费者投诉
+type PayV3Complaint struct {
+	ComplaintID string `json:"complaint_id"`
+	ActionType  string `json:"action_type"`
+}
+
+// ParseNotify 验证并解析回调通知 (验签、校验时间戳、解密资源数据)
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/development/interface-rules/signature-verification.html)
+func (p *PayV3) ParseNotify(r *http.Request) (*PayV3Notify, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 时间戳校验
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderPayTimestamp), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid header timestamp: %w", err)
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); p.window > 0 && (d > p.window || d < -p.window) {
+		return nil, fmt.Errorf("header timestamp expired, timestamp = %d", timestamp)
+	}
+
+	// 签名校验
+	if err = p.Verify(r.Context(), r.Header, body); err != nil {
+		return nil, err
+	}
+
+	notify := new(PayV3Notify)
+	if err = json.Unmarshal(body, notify); err != nil {
+		return nil, err
+	}
+	if notify.Resource == nil {
+		return nil, errors.New("notify resource is empty")
+	}
+
+	notify.plaintext, err = p.DecryptResource(notify.Resource)
+	if err != nil {
+		return nil, err
+	}
+	return notify, nil
+}
+
+// DecryptResource 使用APIv3密钥解密资源数据 (AEAD_AES_256_GCM)
+func (p *PayV3) DecryptResource(res *PayV3NotifyResource) ([]byte, error) {
+	if res.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("unsupported algorithm: %s", res.Algorithm)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(res.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("ciphertext base64.decode error: %w", err)
+	}
+	return xcrypto.AESDecryptGCM([]byte(p.apikey), []byte(res.Nonce), data, []byte(res.AssociatedData), nil)
+}
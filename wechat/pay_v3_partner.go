@@ -0,0 +1,272 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PayV3Partner 支付(v3)服务商模式，与服务商 PayV3 共用签名、验签及平台证书
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/development/development-guidelines/partner-api-call.html)
+type PayV3Partner struct {
+	pay      *PayV3
+	spAppID  string
+	subMchid string
+	subAppID string
+}
+
+// PayV3PartnerOption 服务商模式配置项
+type PayV3PartnerOption func(p *PayV3Partner)
+
+// WithPayV3SubAppID 设置子商户AppID (sub_appid)
+func WithPayV3SubAppID(appid string) PayV3PartnerOption {
+	return func(p *PayV3Partner) {
+		p.subAppID = appid
+	}
+}
+
+// Partner 返回指定子商户的服务商模式客户端 (轻量，可按子商户按需创建)
+//
+//	spAppID：服务商AppID (sp_appid)
+//	subMchid：子商户号 (sub_mchid)
+func (p *PayV3) Partner(spAppID, subMchid string, options ...PayV3PartnerOption) *PayV3Partner {
+	partner := &PayV3Partner{
+		pay:      p,
+		spAppID:  spAppID,
+		subMchid: subMchid,
+	}
+	for _, f := range options {
+		f(partner)
+	}
+	return partner
+}
+
+// Pay 返回服务商 PayV3
+func (p *PayV3Partner) Pay() *PayV3 {
+	return p.pay
+}
+
+// SpMchID 返回服务商商户号
+func (p *PayV3Partner) SpMchID() string {
+	return p.pay.mchid
+}
+
+// SpAppID 返回服务商AppID
+func (p *PayV3Partner) SpAppID() string {
+	return p.spAppID
+}
+
+// SubMchID 返回子商户号
+func (p *PayV3Partner) SubMchID() string {
+	return p.subMchid
+}
+
+// SubAppID 返回子商户AppID
+func (p *PayV3Partner) SubAppID() string {
+	return p.subAppID
+}
+
+// PayV3PartnerPayer 支付者 (服务商模式)
+type PayV3PartnerPayer struct {
+	SpOpenID  string `json:"sp_openid,omitempty"`
+	SubOpenID string `json:"sub_openid,omitempty"`
+}
+
+// PayV3PartnerPrepayRequest 下单请求 (服务商模式，为空的身份信息使用当前客户端配置)
+type PayV3PartnerPrepayRequest struct {
+	SpAppID       string                `json:"sp_appid"`
+	SpMchID       string                `json:"sp_mchid"`
+	SubAppID      string                `json:"sub_appid,omitempty"`
+	SubMchID      string                `json:"sub_mchid"`
+	Description   string                `json:"description"`
+	OutTradeNo    string                `json:"out_trade_no"`
+	TimeExpire    string                `json:"time_expire,omitempty"`
+	Attach        string                `json:"attach,omitempty"`
+	NotifyURL     string                `json:"notify_url"`
+	GoodsTag      string                `json:"goods_tag,omitempty"`
+	SupportFapiao bool                  `json:"support_fapiao,omitempty"`
+	Amount        *PayV3Amount          `json:"amount"`
+	Payer         *PayV3PartnerPayer    `json:"payer,omitempty"` // JSAPI支付必填
+	Detail        *PayV3OrderDetail     `json:"detail,omitempty"`
+	SceneInfo     *PayV3PrepaySceneInfo `json:"scene_info,omitempty"`
+	SettleInfo    *PayV3SettleInfo      `json:"settle_info,omitempty"`
+}
+
+// PayV3PartnerTransaction 支付订单 (服务商模式)
+type PayV3PartnerTransaction struct {
+	SpAppID         string             `json:"sp_appid"`
+	SpMchID         string             `json:"sp_mchid"`
+	SubAppID        string             `json:"sub_appid"`
+	SubMchID        string             `json:"sub_mchid"`
+	OutTradeNo      string             `json:"out_trade_no"`
+	TransactionID   string             `json:"transaction_id"`
+	TradeType       string             `json:"trade_type"`
+	TradeState      string             `json:"trade_state"`
+	TradeStateDesc  string             `json:"trade_state_desc"`
+	BankType        string             `json:"bank_type"`
+	Attach          string             `json:"attach"`
+	SuccessTime     string             `json:"success_time"`
+	Payer           *PayV3PartnerPayer `json:"payer"`
+	Amount          *PayV3Amount       `json:"amount"`
+	SceneInfo       *PayV3SceneInfo    `json:"scene_info"`
+	PromotionDetail []*PayV3Promotion  `json:"promotion_detail"`
+}
+
+// Prepay 下单 (小程序使用JSAPI)
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/partner-jsons/partner-jsapi-prepay.html)
+func (p *PayV3Partner) Prepay(ctx context.Context, tradeType PayV3TradeType, req *PayV3PartnerPrepayRequest) (*PayV3PrepayResponse, error) {
+	req = p.prepayRequest(req)
+
+	ret := new(PayV3PrepayResponse)
+	if err := p.pay.postTyped(ctx, "/v3/pay/partner/transactions/"+string(tradeType), req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// prepayRequest 返回填充了服务商及子商户信息的请求副本 (不修改调用方的请求)
+func (p *PayV3Partner) prepayRequest(req *PayV3PartnerPrepayRequest) *PayV3PartnerPrepayRequest {
+	r := *req
+	if len(r.SpAppID) == 0 {
+		r.SpAppID = p.spAppID
+	}
+	if len(r.SpMchID) == 0 {
+		r.SpMchID = p.pay.mchid
+	}
+	if len(r.SubAppID) == 0 {
+		r.SubAppID = p.subAppID
+	}
+	if len(r.SubMchID) == 0 {
+		r.SubMchID = p.subMchid
+	}
+	return &r
+}
+
+// PrepayWithJSAPI JSAPI下单，并返回JS拉起支付的参数
+//
+//	使用 sub_openid 下单时，以 sub_appid 拉起支付；否则以 sp_appid 拉起支付
+func (p *PayV3Partner) PrepayWithJSAPI(ctx context.Context, req *PayV3PartnerPrepayRequest) (V, error) {
+	req = p.prepayRequest(req)
+
+	ret, err := p.Prepay(ctx, PayV3JSAPI, req)
+	if err != nil {
+		return nil, err
+	}
+
+	appid := req.SpAppID
+	if req.Payer != nil && len(req.Payer.SubOpenID) != 0 {
+		appid = req.SubAppID
+	}
+	return p.pay.JSAPI(appid, ret.PrepayID)
+}
+
+// PrepayWithAPP APP下单，并返回APP拉起支付的参数
+//
+//	下单时传了 sub_appid 以 sub_appid 拉起支付；否则以 sp_appid 拉起支付
+func (p *PayV3Partner) PrepayWithAPP(ctx context.Context, req *PayV3PartnerPrepayRequest) (V, error) {
+	req = p.prepayRequest(req)
+
+	ret, err := p.Prepay(ctx, PayV3APP, req)
+	if err != nil {
+		return nil, err
+	}
+
+	appid := req.SpAppID
+	if len(req.SubAppID) != 0 {
+		appid = req.SubAppID
+	}
+	return p.pay.APPAPI(appid, ret.PrepayID)
+}
+
+// QueryByTransactionID 微信支付订单号查询订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/query-by-wx-trade-no.html)
+func (p *PayV3Partner) QueryByTransactionID(ctx context.Context, transactionID string) (*PayV3PartnerTransaction, error) {
+	ret := new(PayV3PartnerTransaction)
+	if err := p.pay.getTyped(ctx, "/v3/pay/partner/transactions/id/"+url.PathEscape(transactionID), p.query(), ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryByOutTradeNo 商户订单号查询订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/query-by-out-trade-no.html)
+func (p *PayV3Partner) QueryByOutTradeNo(ctx context.Context, outTradeNo string) (*PayV3PartnerTransaction, error) {
+	ret := new(PayV3PartnerTransaction)
+	if err := p.pay.getTyped(ctx, "/v3/pay/partner/transactions/out-trade-no/"+url.PathEscape(outTradeNo), p.query(), ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CloseOrder 关闭订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/close-order.html)
+func (p *PayV3Partner) CloseOrder(ctx context.Context, outTradeNo string) error {
+	_, err := p.pay.PostJSON(ctx, "/v3/pay/partner/transactions/out-trade-no/"+url.PathEscape(outTradeNo)+"/close", X{
+		"sp_mchid":  p.pay.mchid,
+		"sub_mchid": p.subMchid,
+	})
+	return err
+}
+
+// Refund 退款申请
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/create.html)
+func (p *PayV3Partner) Refund(ctx context.Context, req *PayV3RefundRequest) (*PayV3Refund, error) {
+	params, err := toX(req)
+	if err != nil {
+		return nil, err
+	}
+	params["sub_mchid"] = p.subMchid
+
+	ret := new(PayV3Refund)
+	if err = p.pay.postX(ctx, "/v3/refund/domestic/refunds", params, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryRefund 查询单笔退款
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/query-by-out-refund-no.html)
+func (p *PayV3Partner) QueryRefund(ctx context.Context, outRefundNo string) (*PayV3Refund, error) {
+	query := url.Values{}
+	query.Set("sub_mchid", p.subMchid)
+
+	ret := new(PayV3Refund)
+	if err := p.pay.getTyped(ctx, "/v3/refund/domestic/refunds/"+url.PathEscape(outRefundNo), query, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (p *PayV3Partner) query() url.Values {
+	query := url.Values{}
+	query.Set("sp_mchid", p.pay.mchid)
+	query.Set("sub_mchid", p.subMchid)
+	return query
+}
+
+// SubMchID 返回通知所属的子商户号 (服务商模式)，用于按子商户分发处理
+func (n *PayV3Notify) SubMchID() string {
+	return n.Result().Get("sub_mchid").String()
+}
+
+// PartnerTransaction 解析支付成功通知 (服务商模式)
+//
+//	[参考](https://pay.weixin.qq.com/docs/partner/apis/partner-jsapi-payment/payment-notice.html)
+func (n *PayV3Notify) PartnerTransaction() (*PayV3PartnerTransaction, error) {
+	if !strings.HasPrefix(n.EventType, "TRANSACTION.") {
+		return nil, fmt.Errorf("event_type mismatch, expect = TRANSACTION.*, actual = %s", n.EventType)
+	}
+	ret := new(PayV3PartnerTransaction)
+	if err := n.Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
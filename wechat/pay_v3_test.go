@@ -0,0 +1,237 @@
+package wechat
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+const testPayV3ApiKey = "a1b2c3d4e5f6g7h8i9j0k1l2m3n4o5p6"
+
+func testPayV3(t *testing.T) (*PayV3, *rsa.PrivateKey) {
+	prvKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&prvKey.PublicKey)
+	assert.Nil(t, err)
+	pubKey, err := xcrypto.NewPublicKeyFromPemBlock(xcrypto.RSA_PKCS8, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.Nil(t, err)
+
+	pay := NewPayV3("1900000001", testPayV3ApiKey)
+	pay.pubKey.Store(map[string]*xcrypto.PublicKey{"5157F09EFDC096DE15EBE81A47057A72": pubKey})
+
+	return pay, prvKey
+}
+
+func testPayV3NotifyRequest(t *testing.T, key *rsa.PrivateKey, eventType string, resource []byte, timestamp int64) *http.Request {
+	nonce := "fdasflkja484w"
+	ct, err := xcrypto.AESEncryptGCM([]byte(testPayV3ApiKey), []byte(nonce[:12]), resource, []byte("transaction"), nil)
+	assert.Nil(t, err)
+
+	body, err := json.Marshal(X{
+		"id":            "EV-2018022511223320873",
+		"create_time":   "2015-05-20T13:29:35+08:00",
+		"resource_type": "encrypt-resource",
+		"event_type":    eventType,
+		"summary":       "支付成功",
+		"resource": X{
+			"original_type":   "transaction",
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      ct.String(),
+			"associated_data": "transaction",
+			"nonce":           nonce[:12],
+		},
+	})
+	assert.Nil(t, err)
+
+	ts := strconv.FormatInt(timestamp, 10)
+	h := sha256.Sum256([]byte(ts + "\n" + nonce + "\n" + string(body) + "\n"))
+	sign, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	assert.Nil(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/notify", bytes.NewReader(body))
+	r.Header.Set(HeaderPayNonce, nonce)
+	r.Header.Set(HeaderPayTimestamp, ts)
+	r.Header.Set(HeaderPaySerial, "5157F09EFDC096DE15EBE81A47057A72")
+	r.Header.Set(HeaderPaySignature, base64.StdEncoding.EncodeToString(sign))
+
+	return r
+}
+
+func TestPayV3ParseNotify(t *testing.T) {
+	pay, key := testPayV3(t)
+
+	resource := `{"appid":"wxd678efh567hg6787","mchid":"1900000001","out_trade_no":"1217752501201407033233368018","transaction_id":"1217752501201407033233368018","trade_type":"JSAPI","trade_state":"SUCCESS","trade_state_desc":"支付成功","success_time":"2018-06-08T10:34:56+08:00","payer":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"amount":{"total":100,"payer_total":100,"currency":"CNY","payer_currency":"CNY"}}`
+
+	r := testPayV3NotifyRequest(t, key, EventTransactionSuccess, []byte(resource), time.Now().Unix())
+	notify, err := pay.ParseNotify(r)
+	assert.Nil(t, err)
+	assert.Equal(t, resource, string(notify.Plaintext()))
+
+	txn, err := notify.Transaction()
+	assert.Nil(t, err)
+	assert.Equal(t, "1217752501201407033233368018", txn.TransactionID)
+	assert.Equal(t, "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", txn.Payer.OpenID)
+	assert.Equal(t, int64(100), txn.Amount.Total)
+
+	_, err = notify.Refund()
+	assert.NotNil(t, err)
+}
+
+func TestPayV3PartnerNotify(t *testing.T) {
+	pay, key := testPayV3(t)
+
+	resource := `{"sp_appid":"wx8888888888888888","sp_mchid":"1900000001","sub_appid":"wxd678efh567hg6999","sub_mchid":"1900000109","out_trade_no":"1217752501201407033233368018","transaction_id":"1217752501201407033233368018","trade_type":"JSAPI","trade_state":"SUCCESS","payer":{"sp_openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o","sub_openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"amount":{"total":100,"payer_total":100,"currency":"CNY","payer_currency":"CNY"}}`
+
+	r := testPayV3NotifyRequest(t, key, EventTransactionSuccess, []byte(resource), time.Now().Unix())
+	notify, err := pay.ParseNotify(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "1900000109", notify.SubMchID())
+
+	txn, err := notify.PartnerTransaction()
+	assert.Nil(t, err)
+	assert.Equal(t, "1900000001", txn.SpMchID)
+	assert.Equal(t, "wxd678efh567hg6999", txn.SubAppID)
+	assert.Equal(t, "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", txn.Payer.SubOpenID)
+
+	partner := pay.Partner("wx8888888888888888", notify.SubMchID(), WithPayV3SubAppID("wxd678efh567hg6999"))
+	assert.Equal(t, "1900000001", partner.SpMchID())
+	assert.Equal(t, "1900000109", partner.SubMchID())
+	assert.Equal(t, "wxd678efh567hg6999", partner.SubAppID())
+}
+
+func TestPayV3ParseNotifyExpired(t *testing.T) {
+	pay, key := testPayV3(t)
+
+	r := testPayV3NotifyRequest(t, key, EventRefundSuccess, []byte(`{}`), time.Now().Add(-10*time.Minute).Unix())
+	_, err := pay.ParseNotify(r)
+	assert.NotNil(t, err)
+}
+
+func TestPayV3PlatformPublicKey(t *testing.T) {
+	certPay, key := testPayV3(t)
+
+	pubKey, err := certPay.publicKey("5157F09EFDC096DE15EBE81A47057A72")
+	assert.Nil(t, err)
+
+	pay := NewPayV3("1900000001", testPayV3ApiKey, WithPayV3PlatformPublicKey("PUB_KEY_ID_0114232134912410000000000000", pubKey))
+
+	r := testPayV3NotifyRequest(t, key, EventRefundSuccess, []byte(`{"refund_id":"50000000382019052709732678859"}`), time.Now().Unix())
+	r.Header.Set(HeaderPaySerial, "PUB_KEY_ID_0114232134912410000000000000")
+	notify, err := pay.ParseNotify(r)
+	assert.Nil(t, err)
+
+	refund, err := notify.Refund()
+	assert.Nil(t, err)
+	assert.Equal(t, "50000000382019052709732678859", refund.RefundID)
+
+	// 未知的公钥ID
+	_, err = pay.publicKey("PUB_KEY_ID_0000000000000000000000000000")
+	assert.NotNil(t, err)
+	// 未加载平台证书
+	_, err = pay.publicKey("5157F09EFDC096DE15EBE81A47057A72")
+	assert.NotNil(t, err)
+}
+
+func TestPayV3Sensitive(t *testing.T) {
+	certPay, key := testPayV3(t)
+
+	pubKey, err := certPay.publicKey("5157F09EFDC096DE15EBE81A47057A72")
+	assert.Nil(t, err)
+	prvKey, err := xcrypto.NewPrivateKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	assert.Nil(t, err)
+
+	pay := NewPayV3("1900000001", testPayV3ApiKey,
+		WithPayV3PrivateKey("3775B6A45ACD588826D15E583A95F5DD", prvKey),
+		WithPayV3PlatformPublicKey("PUB_KEY_ID_0114232134912410000000000000", pubKey),
+	)
+
+	cipher, serialNO, err := pay.EncryptSensitive("张三")
+	assert.Nil(t, err)
+	assert.Equal(t, "PUB_KEY_ID_0114232134912410000000000000", serialNO)
+
+	plain, err := pay.DecryptSensitive(cipher)
+	assert.Nil(t, err)
+	assert.Equal(t, "张三", plain)
+
+	// 平台证书模式，未加载证书
+	_, _, err = certPay.EncryptSensitive("张三")
+	assert.NotNil(t, err)
+}
+
+func TestPayV3JSAPI(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	prvKey, err := xcrypto.NewPrivateKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	assert.Nil(t, err)
+
+	pay := NewPayV3("1900000001", testPayV3ApiKey, WithPayV3PrivateKey("3775B6A45ACD588826D15E583A95F5DD", prvKey))
+
+	v, err := pay.JSAPI("wxd678efh567hg6787", "wx201410272009395522657a690389285100")
+	assert.Nil(t, err)
+	assert.Equal(t, "prepay_id=wx201410272009395522657a690389285100", v.Get("package"))
+
+	sign, err := base64.StdEncoding.DecodeString(v.Get("paySign"))
+	assert.Nil(t, err)
+
+	msg := "wxd678efh567hg6787\n" + v.Get("timeStamp") + "\n" + v.Get("nonceStr") + "\n" + v.Get("package") + "\n"
+	h := sha256.Sum256([]byte(msg))
+	assert.Nil(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, h[:], sign))
+
+	// 未配置私钥
+	_, err = NewPayV3("1900000001", testPayV3ApiKey).JSAPI("wxd678efh567hg6787", "wx201410272009395522657a690389285100")
+	assert.NotNil(t, err)
+}
+
+func TestParseBill(t *testing.T) {
+	data := "\xef\xbb\xbf交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注\r\n" +
+		"`2014-11-10 16:33:45,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1001690740201411100005734289,`1415640626,`085e9858e3ba5186aafcbaed1,`MICROPAY,`SUCCESS,`OTHERS,`CNY,`0.01,`0.0,`0,`0,`0,`0,`,`,`被扫支付测试,`订单额外描述,`0,`0.60%,`0.01,`0.00,`\r\n" +
+		"`2014-11-10 16:46:14,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1002780740201411100005729794,`1415635270,`085e9858e90ca40c0b5aee463,`MICROPAY,`SUCCESS,`OTHERS,`CNY,`0.01,`0.0,`0,`0,`0,`0,`,`,`被扫支付测试,`订单额外描述,`0,`0.60%,`0.01,`0.00,`\r\n" +
+		"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n" +
+		"`2,`0.02,`0.0,`0.0,`0,`0.02,`0.00\r\n"
+
+	bill, err := ParseBill([]byte(data))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(bill.Records))
+
+	var records []*TradeBillRecord
+	assert.Nil(t, bill.Decode(&records))
+	assert.Equal(t, "1001690740201411100005734289", records[0].TransactionID)
+	assert.Equal(t, "被扫支付测试", records[1].Body)
+	assert.Equal(t, "0.60%", records[1].Rate)
+
+	summary := new(TradeBillSummary)
+	assert.Nil(t, bill.DecodeSummary(summary))
+	assert.Equal(t, "2", summary.TotalCount)
+	assert.Equal(t, "0.02", summary.TotalAmount)
+}
+
+func TestDownloadResultVerify(t *testing.T) {
+	ret := &DownloadResult{
+		HashType:  "SHA1",
+		HashValue: "A9993E364706816ABA3E25717850C26C9CD0D89D",
+		Buffer:    []byte("abc"),
+	}
+	assert.Nil(t, ret.Verify())
+
+	ret.HashType = "SM3"
+	assert.NotNil(t, ret.Verify())
+
+	ret.HashValue = "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0"
+	assert.Nil(t, ret.Verify())
+}
@@ -0,0 +1,358 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+)
+
+// PayV3TradeType 支付(v3)下单类型
+type PayV3TradeType string
+
+const (
+	PayV3JSAPI  PayV3TradeType = "jsapi"  // JSAPI支付 (公众号、小程序)
+	PayV3APP    PayV3TradeType = "app"    // APP支付
+	PayV3H5     PayV3TradeType = "h5"     // H5支付
+	PayV3Native PayV3TradeType = "native" // Native支付
+)
+
+// PayV3H5Info H5场景信息
+type PayV3H5Info struct {
+	Type        string `json:"type"` // iOS, Android, Wap
+	AppName     string `json:"app_name,omitempty"`
+	AppURL      string `json:"app_url,omitempty"`
+	BundleID    string `json:"bundle_id,omitempty"`
+	PackageName string `json:"package_name,omitempty"`
+}
+
+// PayV3StoreInfo 商户门店信息
+type PayV3StoreInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	AreaCode string `json:"area_code,omitempty"`
+	Address  string `json:"address,omitempty"`
+}
+
+// PayV3PrepaySceneInfo 下单场景信息 (H5支付必填)
+type PayV3PrepaySceneInfo struct {
+	PayerClientIP string          `json:"payer_client_ip"`
+	DeviceID      string          `json:"device_id,omitempty"`
+	StoreInfo     *PayV3StoreInfo `json:"store_info,omitempty"`
+	H5Info        *PayV3H5Info    `json:"h5_info,omitempty"`
+}
+
+// PayV3GoodsDetail 单品列表
+type PayV3GoodsDetail struct {
+	MerchantGoodsID  string `json:"merchant_goods_id"`
+	WechatpayGoodsID string `json:"wechatpay_goods_id,omitempty"`
+	GoodsName        string `json:"goods_name,omitempty"`
+	Quantity         int64  `json:"quantity"`
+	UnitPrice        int64  `json:"unit_price"`
+}
+
+// PayV3OrderDetail 优惠功能
+type PayV3OrderDetail struct {
+	CostPrice   int64               `json:"cost_price,omitempty"`
+	InvoiceID   string              `json:"invoice_id,omitempty"`
+	GoodsDetail []*PayV3GoodsDetail `json:"goods_detail,omitempty"`
+}
+
+// PayV3SettleInfo 结算信息
+type PayV3SettleInfo struct {
+	ProfitSharing bool `json:"profit_sharing"`
+}
+
+// PayV3PrepayRequest 下单请求
+type PayV3PrepayRequest struct {
+	AppID         string                `json:"appid"`
+	MchID         string                `json:"mchid"` // 为空时使用当前商户号
+	Description   string                `json:"description"`
+	OutTradeNo    string                `json:"out_trade_no"`
+	TimeExpire    string                `json:"time_expire,omitempty"`
+	Attach        string                `json:"attach,omitempty"`
+	NotifyURL     string                `json:"notify_url"`
+	GoodsTag      string                `json:"goods_tag,omitempty"`
+	SupportFapiao bool                  `json:"support_fapiao,omitempty"`
+	Amount        *PayV3Amount          `json:"amount"`
+	Payer         *PayV3Payer           `json:"payer,omitempty"` // JSAPI支付必填
+	Detail        *PayV3OrderDetail     `json:"detail,omitempty"`
+	SceneInfo     *PayV3PrepaySceneInfo `json:"scene_info,omitempty"`
+	SettleInfo    *PayV3SettleInfo      `json:"settle_info,omitempty"`
+}
+
+// PayV3PrepayResponse 下单结果
+type PayV3PrepayResponse struct {
+	PrepayID string `json:"prepay_id"` // JSAPI/APP
+	H5URL    string `json:"h5_url"`    // H5
+	CodeURL  string `json:"code_url"`  // Native
+}
+
+// PayV3CombineAmount 合单子单金额
+type PayV3CombineAmount struct {
+	TotalAmount   int64  `json:"total_amount"`
+	Currency      string `json:"currency"`
+	PayerAmount   int64  `json:"payer_amount,omitempty"`
+	PayerCurrency string `json:"payer_currency,omitempty"`
+}
+
+// PayV3CombineSubOrder 合单子单
+type PayV3CombineSubOrder struct {
+	MchID         string              `json:"mchid"`
+	Attach        string              `json:"attach"`
+	Amount        *PayV3CombineAmount `json:"amount"`
+	OutTradeNo    string              `json:"out_trade_no"`
+	Description   string              `json:"description"`
+	GoodsTag      string              `json:"goods_tag,omitempty"`
+	SettleInfo    *PayV3SettleInfo    `json:"settle_info,omitempty"`
+	SubMchID      string              `json:"sub_mchid,omitempty"`      // 服务商模式
+	SubAppID      string              `json:"sub_appid,omitempty"`      // 服务商模式
+	TradeType     string              `json:"trade_type,omitempty"`     // 查询结果
+	TradeState    string              `json:"trade_state,omitempty"`    // 查询结果
+	BankType      string              `json:"bank_type,omitempty"`      // 查询结果
+	SuccessTime   string              `json:"success_time,omitempty"`   // 查询结果
+	TransactionID string              `json:"transaction_id,omitempty"` // 查询结果
+}
+
+// PayV3CombineRequest 合单下单请求
+type PayV3CombineRequest struct {
+	CombineAppID      string                  `json:"combine_appid"`
+	CombineMchID      string                  `json:"combine_mchid"` // 为空时使用当前商户号
+	CombineOutTradeNo string                  `json:"combine_out_trade_no"`
+	SceneInfo         *PayV3PrepaySceneInfo   `json:"scene_info,omitempty"`
+	SubOrders         []*PayV3CombineSubOrder `json:"sub_orders"`
+	CombinePayerInfo  *PayV3Payer             `json:"combine_payer_info,omitempty"` // JSAPI支付必填
+	TimeStart         string                  `json:"time_start,omitempty"`
+	TimeExpire        string                  `json:"time_expire,omitempty"`
+	NotifyURL         string                  `json:"notify_url"`
+}
+
+// PayV3CombineTransaction 合单订单
+type PayV3CombineTransaction struct {
+	CombineAppID      string                  `json:"combine_appid"`
+	CombineMchID      string                  `json:"combine_mchid"`
+	CombineOutTradeNo string                  `json:"combine_out_trade_no"`
+	SceneInfo         *PayV3SceneInfo         `json:"scene_info"`
+	SubOrders         []*PayV3CombineSubOrder `json:"sub_orders"`
+	CombinePayerInfo  *PayV3Payer             `json:"combine_payer_info"`
+}
+
+// PayV3RefundReqAmount 退款申请金额
+type PayV3RefundReqAmount struct {
+	Refund   int64  `json:"refund"`
+	Total    int64  `json:"total"`
+	Currency string `json:"currency"`
+}
+
+// PayV3RefundRequest 退款申请 (transaction_id 与 out_trade_no 二选一)
+type PayV3RefundRequest struct {
+	TransactionID string                `json:"transaction_id,omitempty"`
+	OutTradeNo    string                `json:"out_trade_no,omitempty"`
+	OutRefundNo   string                `json:"out_refund_no"`
+	Reason        string                `json:"reason,omitempty"`
+	NotifyURL     string                `json:"notify_url,omitempty"`
+	FundsAccount  string                `json:"funds_account,omitempty"`
+	Amount        *PayV3RefundReqAmount `json:"amount"`
+}
+
+// Prepay 下单 (小程序使用JSAPI)
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/direct-jsons/jsapi-prepay.html)
+func (p *PayV3) Prepay(ctx context.Context, tradeType PayV3TradeType, req *PayV3PrepayRequest) (*PayV3PrepayResponse, error) {
+	// 使用副本，避免修改调用方的请求
+	if len(req.MchID) == 0 {
+		r := *req
+		r.MchID = p.mchid
+		req = &r
+	}
+
+	ret := new(PayV3PrepayResponse)
+	if err := p.postTyped(ctx, "/v3/pay/transactions/"+string(tradeType), req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PrepayWithJSAPI JSAPI下单，并返回JS拉起支付的参数
+func (p *PayV3) PrepayWithJSAPI(ctx context.Context, req *PayV3PrepayRequest) (V, error) {
+	ret, err := p.Prepay(ctx, PayV3JSAPI, req)
+	if err != nil {
+		return nil, err
+	}
+	return p.JSAPI(req.AppID, ret.PrepayID)
+}
+
+// PrepayWithAPP APP下单，并返回APP拉起支付的参数
+func (p *PayV3) PrepayWithAPP(ctx context.Context, req *PayV3PrepayRequest) (V, error) {
+	ret, err := p.Prepay(ctx, PayV3APP, req)
+	if err != nil {
+		return nil, err
+	}
+	return p.APPAPI(req.AppID, ret.PrepayID)
+}
+
+// CombinePrepay 合单下单 (小程序使用JSAPI)
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/combine-payment/orders/jsapi-prepay.html)
+func (p *PayV3) CombinePrepay(ctx context.Context, tradeType PayV3TradeType, req *PayV3CombineRequest) (*PayV3PrepayResponse, error) {
+	// 使用副本，避免修改调用方的请求
+	if len(req.CombineMchID) == 0 {
+		r := *req
+		r.CombineMchID = p.mchid
+		req = &r
+	}
+
+	ret := new(PayV3PrepayResponse)
+	if err := p.postTyped(ctx, "/v3/combine-transactions/"+string(tradeType), req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CombinePrepayWithJSAPI 合单JSAPI下单，并返回JS拉起支付的参数
+func (p *PayV3) CombinePrepayWithJSAPI(ctx context.Context, req *PayV3CombineRequest) (V, error) {
+	ret, err := p.CombinePrepay(ctx, PayV3JSAPI, req)
+	if err != nil {
+		return nil, err
+	}
+	return p.JSAPI(req.CombineAppID, ret.PrepayID)
+}
+
+// CombinePrepayWithAPP 合单APP下单，并返回APP拉起支付的参数
+func (p *PayV3) CombinePrepayWithAPP(ctx context.Context, req *PayV3CombineRequest) (V, error) {
+	ret, err := p.CombinePrepay(ctx, PayV3APP, req)
+	if err != nil {
+		return nil, err
+	}
+	return p.APPAPI(req.CombineAppID, ret.PrepayID)
+}
+
+// QueryByTransactionID 微信支付订单号查询订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/query-by-wx-trade-no.html)
+func (p *PayV3) QueryByTransactionID(ctx context.Context, transactionID string) (*PayV3Transaction, error) {
+	query := url.Values{}
+	query.Set("mchid", p.mchid)
+
+	ret := new(PayV3Transaction)
+	if err := p.getTyped(ctx, "/v3/pay/transactions/id/"+url.PathEscape(transactionID), query, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryByOutTradeNo 商户订单号查询订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/query-by-out-trade-no.html)
+func (p *PayV3) QueryByOutTradeNo(ctx context.Context, outTradeNo string) (*PayV3Transaction, error) {
+	query := url.Values{}
+	query.Set("mchid", p.mchid)
+
+	ret := new(PayV3Transaction)
+	if err := p.getTyped(ctx, "/v3/pay/transactions/out-trade-no/"+url.PathEscape(outTradeNo), query, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CloseOrder 关闭订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/close-order.html)
+func (p *PayV3) CloseOrder(ctx context.Context, outTradeNo string) error {
+	_, err := p.PostJSON(ctx, "/v3/pay/transactions/out-trade-no/"+url.PathEscape(outTradeNo)+"/close", X{"mchid": p.mchid})
+	return err
+}
+
+// CombineQuery 合单查询订单
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/combine-payment/orders/query-order.html)
+func (p *PayV3) CombineQuery(ctx context.Context, combineOutTradeNo string) (*PayV3CombineTransaction, error) {
+	ret := new(PayV3CombineTransaction)
+	if err := p.getTyped(ctx, "/v3/combine-transactions/out-trade-no/"+url.PathEscape(combineOutTradeNo), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CombineClose 合单关闭订单 (subOrders 仅需 mchid、out_trade_no)
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/combine-payment/orders/close-order.html)
+func (p *PayV3) CombineClose(ctx context.Context, combineAppID, combineOutTradeNo string, subOrders ...*PayV3CombineSubOrder) error {
+	if len(subOrders) == 0 {
+		return errors.New("sub_orders is empty")
+	}
+
+	orders := make([]X, 0, len(subOrders))
+	for _, o := range subOrders {
+		orders = append(orders, X{"mchid": o.MchID, "out_trade_no": o.OutTradeNo})
+	}
+
+	_, err := p.PostJSON(ctx, "/v3/combine-transactions/out-trade-no/"+url.PathEscape(combineOutTradeNo)+"/close", X{
+		"combine_appid": combineAppID,
+		"sub_orders":    orders,
+	})
+	return err
+}
+
+// Refund 退款申请
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/create.html)
+func (p *PayV3) Refund(ctx context.Context, req *PayV3RefundRequest) (*PayV3Refund, error) {
+	ret := new(PayV3Refund)
+	if err := p.postTyped(ctx, "/v3/refund/domestic/refunds", req, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// QueryRefund 查询单笔退款
+//
+//	[参考](https://pay.weixin.qq.com/docs/merchant/apis/jsapi-payment/query-by-out-refund-no.html)
+func (p *PayV3) QueryRefund(ctx context.Context, outRefundNo string) (*PayV3Refund, error) {
+	ret := new(PayV3Refund)
+	if err := p.getTyped(ctx, "/v3/refund/domestic/refunds/"+url.PathEscape(outRefundNo), nil, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (p *PayV3) getTyped(ctx context.Context, path string, query url.Values, resp any) error {
+	ret, err := p.GetJSON(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(ret.Body.Raw), resp)
+}
+
+func (p *PayV3) postTyped(ctx context.Context, path string, req, resp any) error {
+	params, err := toX(req)
+	if err != nil {
+		return err
+	}
+	return p.postX(ctx, path, params, resp)
+}
+
+func (p *PayV3) postX(ctx context.Context, path string, params X, resp any) error {
+	ret, err := p.PostJSON(ctx, path, params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(ret.Body.Raw), resp)
+}
+
+// toX 将结构体转为X (使用 json.Number 避免金额等整数被转为浮点数)
+func toX(v any) (X, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	x := X{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err = decoder.Decode(&x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
@@ -0,0 +1,298 @@
+package wechat
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal/xcrypto"
+)
+
+type testPayV3Route struct {
+	method string
+	path   string
+	query  string
+	body   func(t *testing.T, body gjson.Result) // 校验请求Body
+	resp   string
+	status int
+}
+
+// testPayV3Server 模拟微信支付(v3)接口，校验请求路径并使用平台私钥签名应答
+func testPayV3Server(t *testing.T, routes ...*testPayV3Route) *PayV3 {
+	pay, platKey := testPayV3(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	prvKey, err := xcrypto.NewPrivateKeyFromPemBlock(xcrypto.RSA_PKCS1, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	assert.Nil(t, err)
+	WithPayV3PrivateKey("3775B6A45ACD588826D15E583A95F5DD", prvKey)(pay)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range routes {
+			if r.Method != route.method || r.URL.Path != route.path {
+				continue
+			}
+			assert.Equal(t, route.query, r.URL.RawQuery)
+			assert.Contains(t, r.Header.Get("Authorization"), `mchid="1900000001"`)
+
+			b, _ := io.ReadAll(r.Body)
+			if route.body != nil {
+				route.body(t, gjson.ParseBytes(b))
+			}
+
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			nonce := "593BEC0C930BF1AFEB40B4A08C8FB242"
+			h := sha256.Sum256([]byte(ts + "\n" + nonce + "\n" + route.resp + "\n"))
+			sign, err := rsa.SignPKCS1v15(rand.Reader, platKey, crypto.SHA256, h[:])
+			assert.Nil(t, err)
+
+			w.Header().Set(HeaderPayNonce, nonce)
+			w.Header().Set(HeaderPayTimestamp, ts)
+			w.Header().Set(HeaderPaySerial, "5157F09EFDC096DE15EBE81A47057A72")
+			w.Header().Set(HeaderPaySignature, base64.StdEncoding.EncodeToString(sign))
+			if route.status != 0 {
+				w.WriteHeader(route.status)
+			}
+			w.Write([]byte(route.resp))
+			return
+		}
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	pay.host = srv.URL
+	return pay
+}
+
+func TestPayV3Prepay(t *testing.T) {
+	pay := testPayV3Server(t, &testPayV3Route{
+		method: http.MethodPost,
+		path:   "/v3/pay/transactions/jsapi",
+		body: func(t *testing.T, body gjson.Result) {
+			assert.Equal(t, "1900000001", body.Get("mchid").String())
+			assert.Equal(t, "wxd678efh567hg6787", body.Get("appid").String())
+			assert.Equal(t, "1217752501201407033233368018", body.Get("out_trade_no").String())
+			assert.Equal(t, int64(100), body.Get("amount.total").Int())
+			assert.Equal(t, "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", body.Get("payer.openid").String())
+			assert.False(t, body.Get("detail").Exists())
+		},
+		resp:   `{"prepay_id":"wx26112221580621e9b071c00d9e093b0000"}`,
+		status: http.StatusOK,
+	})
+
+	req := &PayV3PrepayRequest{
+		AppID:       "wxd678efh567hg6787",
+		Description: "Image形象店-深圳腾大-QQ公仔",
+		OutTradeNo:  "1217752501201407033233368018",
+		NotifyURL:   "https://www.weixin.qq.com/wxpay/pay.php",
+		Amount:      &PayV3Amount{Total: 100, Currency: "CNY"},
+		Payer:       &PayV3Payer{OpenID: "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},
+	}
+
+	v, err := pay.PrepayWithJSAPI(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "prepay_id=wx26112221580621e9b071c00d9e093b0000", v.Get("package"))
+	assert.Equal(t, "wxd678efh567hg6787", v.Get("appId"))
+
+	// 不修改调用方的请求
+	assert.Empty(t, req.MchID)
+}
+
+func TestPayV3Query(t *testing.T) {
+	transaction := `{"appid":"wxd678efh567hg6787","mchid":"1900000001","out_trade_no":"1217752501201407033233368018","transaction_id":"1217752501201407033233368018","trade_type":"JSAPI","trade_state":"SUCCESS","trade_state_desc":"支付成功","success_time":"2018-06-08T10:34:56+08:00","payer":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},"amount":{"total":100,"payer_total":100,"currency":"CNY","payer_currency":"CNY"}}`
+
+	pay := testPayV3Server(t,
+		&testPayV3Route{
+			method: http.MethodGet,
+			path:   "/v3/pay/transactions/id/1217752501201407033233368018",
+			query:  "mchid=1900000001",
+			resp:   transaction,
+		},
+		&testPayV3Route{
+			method: http.MethodGet,
+			path:   "/v3/pay/transactions/out-trade-no/1217752501201407033233368018",
+			query:  "mchid=1900000001",
+			resp:   transaction,
+		},
+		&testPayV3Route{
+			method: http.MethodPost,
+			path:   "/v3/pay/transactions/out-trade-no/1217752501201407033233368018/close",
+			body: func(t *testing.T, body gjson.Result) {
+				assert.JSONEq(t, `{"mchid":"1900000001"}`, body.Raw)
+			},
+			status: http.StatusNoContent,
+		},
+	)
+
+	ctx := context.Background()
+
+	ret, err := pay.QueryByTransactionID(ctx, "1217752501201407033233368018")
+	assert.Nil(t, err)
+	assert.Equal(t, "SUCCESS", ret.TradeState)
+	assert.Equal(t, int64(100), ret.Amount.Total)
+	assert.Equal(t, "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o", ret.Payer.OpenID)
+
+	ret, err = pay.QueryByOutTradeNo(ctx, "1217752501201407033233368018")
+	assert.Nil(t, err)
+	assert.Equal(t, "1217752501201407033233368018", ret.TransactionID)
+
+	assert.Nil(t, pay.CloseOrder(ctx, "1217752501201407033233368018"))
+}
+
+func TestPayV3Refund(t *testing.T) {
+	refund := `{"refund_id":"50000000382019052709732678859","out_refund_no":"1217752501201407033233368018","transaction_id":"1217752501201407033233368018","out_trade_no":"1217752501201407033233368018","channel":"ORIGINAL","user_received_account":"招商银行信用卡0403","create_time":"2020-12-01T16:18:12+08:00","status":"PROCESSING","funds_account":"AVAILABLE","amount":{"total":100,"refund":100,"payer_total":90,"payer_refund":90,"settlement_refund":100,"settlement_total":100,"discount_refund":10,"currency":"CNY"}}`
+
+	pay := testPayV3Server(t,
+		&testPayV3Route{
+			method: http.MethodPost,
+			path:   "/v3/refund/domestic/refunds",
+			body: func(t *testing.T, body gjson.Result) {
+				assert.Equal(t, "1217752501201407033233368018", body.Get("out_trade_no").String())
+				assert.Equal(t, "1217752501201407033233368018", body.Get("out_refund_no").String())
+				assert.Equal(t, int64(100), body.Get("amount.refund").Int())
+				assert.Equal(t, int64(100), body.Get("amount.total").Int())
+				assert.False(t, body.Get("transaction_id").Exists())
+			},
+			resp: refund,
+		},
+		&testPayV3Route{
+			method: http.MethodGet,
+			path:   "/v3/refund/domestic/refunds/1217752501201407033233368018",
+			resp:   refund,
+		},
+		&testPayV3Route{
+			method: http.MethodGet,
+			path:   "/v3/refund/domestic/refunds/NOT_EXIST",
+			resp:   `{"code":"RESOURCE_NOT_EXISTS","message":"退款单不存在"}`,
+			status: http.StatusNotFound,
+		},
+	)
+
+	ctx := context.Background()
+
+	ret, err := pay.Refund(ctx, &PayV3RefundRequest{
+		OutTradeNo:  "1217752501201407033233368018",
+		OutRefundNo: "1217752501201407033233368018",
+		Amount:      &PayV3RefundReqAmount{Refund: 100, Total: 100, Currency: "CNY"},
+	})
+	assert.Nil(t, err)
+	// 退款申请/查询返回 status，退款通知返回 refund_status
+	assert.Equal(t, "PROCESSING", ret.Status)
+	assert.Empty(t, ret.RefundStatus)
+	assert.Equal(t, int64(90), ret.Amount.PayerRefund)
+
+	ret, err = pay.QueryRefund(ctx, "1217752501201407033233368018")
+	assert.Nil(t, err)
+	assert.Equal(t, "50000000382019052709732678859", ret.RefundID)
+	assert.Equal(t, "PROCESSING", ret.Status)
+
+	_, err = pay.QueryRefund(ctx, "NOT_EXIST")
+	var e *APIError
+	assert.ErrorAs(t, err, &e)
+	assert.Equal(t, "RESOURCE_NOT_EXISTS", e.ErrCode)
+}
+
+func TestPayV3Combine(t *testing.T) {
+	pay := testPayV3Server(t,
+		&testPayV3Route{
+			method: http.MethodPost,
+			path:   "/v3/combine-transactions/app",
+			body: func(t *testing.T, body gjson.Result) {
+				assert.Equal(t, "wxd678efh567hg6787", body.Get("combine_appid").String())
+				assert.Equal(t, "1900000001", body.Get("combine_mchid").String())
+				assert.Equal(t, "P20150806125346", body.Get("combine_out_trade_no").String())
+				assert.Len(t, body.Get("sub_orders").Array(), 2)
+				assert.Equal(t, "1900000109", body.Get("sub_orders.0.mchid").String())
+				assert.Equal(t, int64(10), body.Get("sub_orders.0.amount.total_amount").Int())
+				assert.Equal(t, "CNY", body.Get("sub_orders.1.amount.currency").String())
+				assert.False(t, body.Get("sub_orders.0.trade_state").Exists())
+			},
+			resp: `{"prepay_id":"wx201410272009395522657a690389285100"}`,
+		},
+		&testPayV3Route{
+			method: http.MethodGet,
+			path:   "/v3/combine-transactions/out-trade-no/P20150806125346",
+			resp:   `{"combine_appid":"wxd678efh567hg6787","combine_mchid":"1900000001","combine_out_trade_no":"P20150806125346","sub_orders":[{"mchid":"1900000109","trade_type":"APP","trade_state":"SUCCESS","transaction_id":"4200000000000000000000000001","out_trade_no":"20150806125346","amount":{"total_amount":10,"currency":"CNY","payer_amount":10,"payer_currency":"CNY"}}],"combine_payer_info":{"openid":"oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"}}`,
+		},
+		&testPayV3Route{
+			method: http.MethodPost,
+			path:   "/v3/combine-transactions/out-trade-no/P20150806125346/close",
+			body: func(t *testing.T, body gjson.Result) {
+				assert.JSONEq(t, `{"combine_appid":"wxd678efh567hg6787","sub_orders":[{"mchid":"1900000109","out_trade_no":"20150806125346"}]}`, body.Raw)
+			},
+			status: http.StatusNoContent,
+		},
+	)
+
+	ctx := context.Background()
+
+	req := &PayV3CombineRequest{
+		CombineAppID:      "wxd678efh567hg6787",
+		CombineOutTradeNo: "P20150806125346",
+		NotifyURL:         "https://yourapp.com/notify",
+		SubOrders: []*PayV3CombineSubOrder{
+			{MchID: "1900000109", Attach: "深圳分店", OutTradeNo: "20150806125346", Description: "腾讯充值中心-QQ会员充值", Amount: &PayV3CombineAmount{TotalAmount: 10, Currency: "CNY"}},
+			{MchID: "1900000110", Attach: "深圳分店", OutTradeNo: "20150806125347", Description: "腾讯充值中心-QQ会员充值", Amount: &PayV3CombineAmount{TotalAmount: 20, Currency: "CNY"}},
+		},
+	}
+
+	v, err := pay.CombinePrepayWithAPP(ctx, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "wx201410272009395522657a690389285100", v.Get("prepayid"))
+	assert.Empty(t, req.CombineMchID)
+
+	ret, err := pay.CombineQuery(ctx, "P20150806125346")
+	assert.Nil(t, err)
+	assert.Len(t, ret.SubOrders, 1)
+	assert.Equal(t, "SUCCESS", ret.SubOrders[0].TradeState)
+	assert.Equal(t, int64(10), ret.SubOrders[0].Amount.PayerAmount)
+
+	assert.Nil(t, pay.CombineClose(ctx, "wxd678efh567hg6787", "P20150806125346", req.SubOrders[0]))
+	assert.NotNil(t, pay.CombineClose(ctx, "wxd678efh567hg6787", "P20150806125346"))
+}
+
+func TestPayV3PartnerPrepay(t *testing.T) {
+	pay := testPayV3Server(t, &testPayV3Route{
+		method: http.MethodPost,
+		path:   "/v3/pay/partner/transactions/jsapi",
+		body: func(t *testing.T, body gjson.Result) {
+			assert.Equal(t, "wx8888888888888888", body.Get("sp_appid").String())
+			assert.Equal(t, "1900000001", body.Get("sp_mchid").String())
+			assert.Equal(t, "wxd678efh567hg6999", body.Get("sub_appid").String())
+			assert.Equal(t, "1900000109", body.Get("sub_mchid").String())
+		},
+		resp: `{"prepay_id":"wx26112221580621e9b071c00d9e093b0000"}`,
+	})
+
+	req := &PayV3PartnerPrepayRequest{
+		Description: "Image形象店-深圳腾大-QQ公仔",
+		OutTradeNo:  "1217752501201407033233368018",
+		NotifyURL:   "https://www.weixin.qq.com/wxpay/pay.php",
+		Amount:      &PayV3Amount{Total: 100, Currency: "CNY"},
+		Payer:       &PayV3PartnerPayer{SubOpenID: "oUpF8uMuAJO_M2pxb1Q9zNjWeS6o"},
+	}
+
+	// 使用 sub_openid 下单，以 sub_appid 拉起支付
+	v, err := pay.Partner("wx8888888888888888", "1900000109", WithPayV3SubAppID("wxd678efh567hg6999")).PrepayWithJSAPI(context.Background(), req)
+	assert.Nil(t, err)
+	assert.Equal(t, "wxd678efh567hg6999", v.Get("appId"))
+
+	// 不修改调用方的请求
+	assert.Empty(t, req.SpAppID)
+	assert.Empty(t, req.SubMchID)
+}
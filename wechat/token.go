@@ -0,0 +1,330 @@
+package wechat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/yiigo/sdk-go/internal"
+)
+
+const (
+	tokenAhead   = 5 * time.Minute  // 提前刷新的时间
+	tokenLockTTL = 30 * time.Second // 刷新锁的有效期
+)
+
+// Token 接口调用凭据 (如：access_token)
+type Token struct {
+	Value    string    `json:"value"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+// Valid 凭据是否有效
+func (t *Token) Valid() bool {
+	return t != nil && len(t.Value) != 0 && time.Now().Before(t.ExpireAt)
+}
+
+// NewToken 根据接口返回的 expires_in(秒) 生成凭据
+func NewToken(value string, expiresIn int64) *Token {
+	if expiresIn <= 0 {
+		expiresIn = 7200
+	}
+	return &Token{
+		Value:    value,
+		ExpireAt: time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}
+}
+
+// TokenStore 凭据存储
+//
+//	多实例部署时，可基于Redis等实现共享存储，避免各实例分别刷新导致凭据互相失效
+type TokenStore interface {
+	// Get 获取凭据，不存在时返回 nil
+	Get(ctx context.Context, key string) (*Token, error)
+
+	// Set 保存凭据
+	Set(ctx context.Context, key string, token *Token) error
+}
+
+// TokenLocker 凭据刷新锁 (可选)
+//
+//	TokenStore 实现该接口时，仅获得锁的实例会刷新凭据，其它实例读取共享的凭据
+//	锁须有持有者标识，避免刷新耗时超过ttl时误删其它实例的锁，Redis 实现参考：
+//
+//	Lock:   SET key owner NX PX ttl (owner 为随机串)
+//	Unlock: EVAL "if redis.call('GET', KEYS[1]) == ARGV[1] then return redis.call('DEL', KEYS[1]) else return 0 end" 1 key owner
+type TokenLocker interface {
+	// Lock 尝试加锁，成功返回持有者标识，锁被占用时返回空串
+	Lock(ctx context.Context, key string, ttl time.Duration) (owner string, err error)
+
+	// Unlock 释放锁，仅当锁仍由owner持有时删除 (Compare-And-Delete)
+	Unlock(ctx context.Context, key, owner string) error
+}
+
+type memTokenStore struct {
+	tokens sync.Map
+}
+
+func (s *memTokenStore) Get(ctx context.Context, key string) (*Token, error) {
+	v, ok := s.tokens.Load(key)
+	if !ok {
+		return nil, nil
+	}
+	return v.(*Token), nil
+}
+
+func (s *memTokenStore) Set(ctx context.Context, key string, token *Token) error {
+	s.tokens.Store(key, token)
+	return nil
+}
+
+// NewMemTokenStore 生成基于内存的凭据存储 (默认)
+func NewMemTokenStore() TokenStore {
+	return new(memTokenStore)
+}
+
+type tokenSource struct {
+	key   string
+	store TokenStore
+	load  func(ctx context.Context, force bool) (*Token, error)
+	retry bool // 凭据失效时是否刷新并重试
+	lazy  bool // 请求时按需加载 (无需 AutoLoad，如：第三方应用的授权企业)
+	mutex sync.Mutex
+
+	expired func(code int64) bool // 凭据失效的错误码判断 (默认：isTokenExpired)
+}
+
+func (ts *tokenSource) get(ctx context.Context) (string, error) {
+	t, err := ts.store.Get(ctx, ts.key)
+	if err != nil {
+		return "", err
+	}
+	if t == nil || len(t.Value) == 0 {
+		return "", errors.New("access_token is empty (forgotten auto load?)")
+	}
+	return t.Value, nil
+}
+
+// fetch 获取凭据 (按需加载时，临近过期先刷新)
+func (ts *tokenSource) fetch(ctx context.Context) (string, error) {
+	if ts.lazy {
+		if err := ts.refresh(ctx, tokenAhead); err != nil {
+			return "", err
+		}
+	}
+	return ts.get(ctx)
+}
+
+// newTicketSource 生成依附于 access_token 的票据 (如：jsapi_ticket)，与 access_token 共用存储，按需加载
+func newTicketSource(token *tokenSource, name string, load func(ctx context.Context) (*Token, error)) *tokenSource {
+	return &tokenSource{
+		key:   strings.TrimSuffix(token.key, "access_token") + name,
+		store: token.store,
+		lazy:  true,
+		load: func(ctx context.Context, force bool) (*Token, error) {
+			return load(ctx)
+		},
+	}
+}
+
+// fresh 判断凭据距过期是否超过ahead
+func (ts *tokenSource) fresh(ctx context.Context, ahead time.Duration) (bool, error) {
+	t, err := ts.store.Get(ctx, ts.key)
+	if err != nil {
+		return false, err
+	}
+	return t != nil && len(t.Value) != 0 && time.Until(t.ExpireAt) > ahead, nil
+}
+
+// refresh 凭据距过期不足ahead时刷新
+func (ts *tokenSource) refresh(ctx context.Context, ahead time.Duration) error {
+	if ok, err := ts.fresh(ctx, ahead); err != nil || ok {
+		return err
+	}
+
+	// 同一实例内并发请求仅刷新一次
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	if ok, err := ts.fresh(ctx, ahead); err != nil || ok {
+		return err
+	}
+
+	unlock, ok, err := ts.lock(ctx)
+	if err != nil {
+		return err
+	}
+	// 其它实例正在刷新
+	if !ok {
+		return ts.wait(ctx, "")
+	}
+	defer unlock()
+
+	// 获得锁后再次检查，避免重复刷新
+	if ok, err = ts.fresh(ctx, ahead); err != nil || ok {
+		return err
+	}
+
+	t, err := ts.load(ctx, false)
+	if err != nil {
+		return err
+	}
+	return ts.store.Set(ctx, ts.key, t)
+}
+
+// renew 凭据失效时强制刷新 (stale 为失效的凭据)
+func (ts *tokenSource) renew(ctx context.Context, stale string) error {
+	if ts.load == nil {
+		return errors.New("access_token loader is nil (forgotten auto load?)")
+	}
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	// 已被其它请求刷新
+	renewed := func() (bool, error) {
+		t, err := ts.store.Get(ctx, ts.key)
+		if err != nil {
+			return false, err
+		}
+		return t.Valid() && t.Value != stale, nil
+	}
+
+	if ok, err := renewed(); err != nil || ok {
+		return err
+	}
+
+	unlock, ok, err := ts.lock(ctx)
+	if err != nil {
+		return err
+	}
+	// 其它实例正在刷新
+	if !ok {
+		return ts.wait(ctx, stale)
+	}
+	defer unlock()
+
+	if ok, err = renewed(); err != nil || ok {
+		return err
+	}
+
+	t, err := ts.load(ctx, true)
+	if err != nil {
+		return err
+	}
+	return ts.store.Set(ctx, ts.key, t)
+}
+
+// lock 获取刷新锁 (存储未实现 TokenLocker 时直接返回成功)，ok 为 false 表示其它实例正在刷新
+func (ts *tokenSource) lock(ctx context.Context) (unlock func(), ok bool, err error) {
+	locker, ok := ts.store.(TokenLocker)
+	if !ok {
+		return func() {}, true, nil
+	}
+
+	lockKey := ts.key + ":lock"
+
+	owner, err := locker.Lock(ctx, lockKey, tokenLockTTL)
+	if err != nil || len(owner) == 0 {
+		return nil, false, err
+	}
+	return func() {
+		locker.Unlock(context.Background(), lockKey, owner)
+	}, true, nil
+}
+
+// do 携带凭据发起请求，凭据失效时强制刷新并重试一次
+func (ts *tokenSource) do(ctx context.Context, fn func(token string) ([]byte, error)) ([]byte, error) {
+	token, err := ts.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := fn(token)
+	if err != nil || !ts.retry {
+		return b, err
+	}
+
+	expired := ts.expired
+	if expired == nil {
+		expired = isTokenExpired
+	}
+	if !expired(gjson.GetBytes(b, "errcode").Int()) {
+		return b, nil
+	}
+
+	if err = ts.renew(ctx, token); err != nil {
+		return nil, err
+	}
+	if token, err = ts.get(ctx); err != nil {
+		return nil, err
+	}
+	return fn(token)
+}
+
+// isTokenExpired 是否为凭据失效的错误码
+func isTokenExpired(code int64) bool {
+	switch code {
+	case 40001, // 不合法的凭证
+		40014, // 不合法的 access_token
+		42001: // access_token 超时
+		return true
+	}
+	return false
+}
+
+// isSuiteTokenExpired 是否为 suite_access_token 失效的错误码 (企业微信第三方应用)
+func isSuiteTokenExpired(code int64) bool {
+	switch code {
+	case 40082, // 不合法的 suite_access_token
+		42009: // suite_access_token 超时
+		return true
+	}
+	return isTokenExpired(code)
+}
+
+// wait 等待其它实例刷新凭据 (stale 为失效的凭据)
+func (ts *tokenSource) wait(ctx context.Context, stale string) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	deadline := time.Now().Add(tokenLockTTL)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		t, err := ts.store.Get(ctx, ts.key)
+		if err != nil {
+			return err
+		}
+		if t.Valid() && t.Value != stale {
+			return nil
+		}
+		timer.Reset(100 * time.Millisecond)
+	}
+	return errors.New("wait for token refresh timeout")
+}
+
+// autoLoad 初始化凭据并定时刷新，直至ctx取消
+func (ts *tokenSource) autoLoad(ctx context.Context, interval time.Duration, onErr func(ctx context.Context, err error)) error {
+	refresh := func(ctx context.Context) error {
+		return ts.refresh(ctx, interval+tokenAhead)
+	}
+
+	// 初始化
+	if err := refresh(ctx); err != nil {
+		return err
+	}
+
+	// 异步定时加载
+	go internal.Refresh(ctx, interval, refresh, onErr)
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package wechat
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+type testLockStore struct {
+	TokenStore
+
+	mutex sync.Mutex
+	locks map[string]string
+	seq   int
+}
+
+func (s *testLockStore) Lock(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.locks[key]) != 0 {
+		return "", nil
+	}
+	s.seq++
+	s.locks[key] = strconv.Itoa(s.seq)
+	return s.locks[key], nil
+}
+
+func (s *testLockStore) Unlock(ctx context.Context, key, owner string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.locks[key] == owner {
+		delete(s.locks, key)
+	}
+	return nil
+}
+
+// expire 模拟锁过期
+func (s *testLockStore) expire(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.locks, key)
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	ctx := context.Background()
+	store := &testLockStore{TokenStore: NewMemTokenStore(), locks: make(map[string]string)}
+
+	var loads int32
+	load := func(ctx context.Context, force bool) (*Token, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(50 * time.Millisecond)
+		return NewToken("ACCESS_TOKEN", 7200), nil
+	}
+
+	// 模拟多个实例共享存储
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		ts := &tokenSource{key: "wechat:mp:wx123:access_token", store: store, load: load}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, ts.refresh(ctx, time.Hour))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	ts := &tokenSource{key: "wechat:mp:wx123:access_token", store: store, load: load}
+	token, err := ts.get(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "ACCESS_TOKEN", token)
+
+	// 距过期不足ahead时刷新
+	assert.Nil(t, ts.refresh(ctx, 3*time.Hour))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+}
+
+func TestTokenSourceFetch(t *testing.T) {
+	ctx := context.Background()
+
+	var loads int32
+	ts := &tokenSource{key: "wechat:corp:ww123:access_token", store: NewMemTokenStore(), lazy: true, load: func(ctx context.Context, force bool) (*Token, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(50 * time.Millisecond)
+		return NewToken("ACCESS_TOKEN", 7200), nil
+	}}
+
+	// 并发请求仅加载一次
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.fetch(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, "ACCESS_TOKEN", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
+func TestTokenLockOwner(t *testing.T) {
+	ctx := context.Background()
+	store := &testLockStore{TokenStore: NewMemTokenStore(), locks: make(map[string]string)}
+
+	key := "wechat:mp:wx123:access_token"
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	ts := &tokenSource{key: key, store: store, load: func(ctx context.Context, force bool) (*Token, error) {
+		close(loading)
+		<-release
+		return NewToken("ACCESS_TOKEN", 7200), nil
+	}}
+
+	done := make(chan error)
+	go func() {
+		done <- ts.refresh(ctx, time.Hour)
+	}()
+	<-loading
+
+	// 刷新耗时超过ttl，锁过期后被其它实例获得
+	store.expire(key + ":lock")
+	owner, err := store.Lock(ctx, key+":lock", tokenLockTTL)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, owner)
+
+	close(release)
+	assert.Nil(t, <-done)
+
+	// 不会误删其它实例的锁
+	owner2, err := store.Lock(ctx, key+":lock", tokenLockTTL)
+	assert.Nil(t, err)
+	assert.Empty(t, owner2)
+	assert.Nil(t, store.Unlock(ctx, key+":lock", owner))
+}
+
+func TestTokenRetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get(AccessToken) != "NEW_TOKEN" {
+			w.Write([]byte(`{"errcode":40001,"errmsg":"invalid credential"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var loads int32
+	loader := func(ctx context.Context, mp *MiniProgram) (*Token, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			return NewToken("OLD_TOKEN", 7200), nil
+		}
+		return NewToken("NEW_TOKEN", 7200), nil
+	}
+
+	mp := NewMiniProgram("wx123", "secret")
+	mp.host = srv.URL
+	assert.Nil(t, mp.CustomAccessTokenLoad(ctx, loader, time.Hour))
+
+	_, err := mp.PostJSON(ctx, "/wxa/msg_sec_check", X{"content": "hello"})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+
+	// 关闭重试
+	atomic.StoreInt32(&loads, 0)
+	mp = NewMiniProgram("wx123", "secret", WithMPTokenRetry(false))
+	mp.host = srv.URL
+	assert.Nil(t, mp.CustomAccessTokenLoad(ctx, loader, time.Hour))
+
+	_, err = mp.PostJSON(ctx, "/wxa/msg_sec_check", X{"content": "hello"})
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
+func TestTokenExpiredCode(t *testing.T) {
+	ctx := context.Background()
+
+	newSource := func(expired func(code int64) bool) (*tokenSource, *int32) {
+		loads := new(int32)
+		return &tokenSource{
+			key:     "wechat:corp_suite:ww123:suite_access_token",
+			store:   NewMemTokenStore(),
+			retry:   true,
+			lazy:    true,
+			expired: expired,
+			load: func(ctx context.Context, force bool) (*Token, error) {
+				atomic.AddInt32(loads, 1)
+				return NewToken("TOKEN_"+strconv.Itoa(int(atomic.LoadInt32(loads))), 7200), nil
+			},
+		}, loads
+	}
+
+	fn := func(token string) ([]byte, error) {
+		if token == "TOKEN_1" {
+			return []byte(`{"errcode":42009,"errmsg":"suite_access_token expired"}`), nil
+		}
+		return []byte(`{"errcode":0,"errmsg":"ok"}`), nil
+	}
+
+	// 默认 (公众号、小程序等) 不视为凭据失效
+	ts, loads := newSource(nil)
+	b, err := ts.do(ctx, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42009), gjson.GetBytes(b, "errcode").Int())
+	assert.Equal(t, int32(1), atomic.LoadInt32(loads))
+
+	// suite_access_token 失效时刷新并重试
+	ts, loads = newSource(isSuiteTokenExpired)
+	b, err = ts.do(ctx, fn)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), gjson.GetBytes(b, "errcode").Int())
+	assert.Equal(t, int32(2), atomic.LoadInt32(loads))
+}
+
+func TestUploadRetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("media")
+		assert.Nil(t, err)
+		b, err := io.ReadAll(f)
+		assert.Nil(t, err)
+		// 重试时从初始位置重新读取
+		assert.Equal(t, "IMAGE", string(b))
+
+		if r.URL.Query().Get(AccessToken) != "NEW_TOKEN" {
+			w.Write([]byte(`{"errcode":40001,"errmsg":"invalid credential"}`))
+			return
+		}
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok","media_id":"MEDIA_ID"}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var loads int32
+	loader := func(ctx context.Context, mp *MiniProgram) (*Token, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			return NewToken("OLD_TOKEN", 7200), nil
+		}
+		return NewToken("NEW_TOKEN", 7200), nil
+	}
+
+	mp := NewMiniProgram("wx123", "secret")
+	mp.host = srv.URL
+	assert.Nil(t, mp.CustomAccessTokenLoad(ctx, loader, time.Hour))
+
+	// reader 已跳过文件头
+	reader := strings.NewReader("HEADERIMAGE")
+	_, err := reader.Seek(6, io.SeekStart)
+	assert.Nil(t, err)
+
+	ret, err := mp.UploadWithReader(ctx, "/cgi-bin/media/upload", "media", "a.jpg", reader, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "MEDIA_ID", ret.Get("media_id").String())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+}
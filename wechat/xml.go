@@ -3,18 +3,26 @@ package wechat
 import (
 	"bytes"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
-// ValueToXML value to xml
+// ValueToXML value to xml (按键名排序输出)
 func ValueToXML(vals V) (string, error) {
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	var builder strings.Builder
 
 	builder.WriteString("<xml>")
-	for k, v := range vals {
+	for _, k := range keys {
 		builder.WriteString("<" + k + ">")
-		if err := xml.EscapeText(&builder, []byte(v)); err != nil {
+		if err := xml.EscapeText(&builder, []byte(vals[k])); err != nil {
 			return "", err
 		}
 		builder.WriteString("</" + k + ">")
@@ -24,7 +32,7 @@ func ValueToXML(vals V) (string, error) {
 	return builder.String(), nil
 }
 
-// XMLToValue xml to value
+// XMLToValue xml to value (仅解析第一层元素，嵌套元素请使用 XMLToX)
 func XMLToValue(b []byte) (V, error) {
 	m := make(V)
 
@@ -75,3 +83,171 @@ func XMLToValue(b []byte) (V, error) {
 		}
 	}
 }
+
+// XToXML 将X编码为XML (根节点为<xml>，按键名排序输出)
+//
+//	string -> CDATA；X -> 嵌套元素；切片 -> 同名重复元素；其它类型 -> 文本
+//	如：X{"Articles": X{"item": []X{{"Title": "a"}, {"Title": "b"}}}}
+func XToXML(x X) (string, error) {
+	var builder strings.Builder
+
+	builder.WriteString("<xml>")
+	if err := encodeXMLElements(&builder, x); err != nil {
+		return "", err
+	}
+	builder.WriteString("</xml>")
+
+	return builder.String(), nil
+}
+
+func encodeXMLElements(builder *strings.Builder, x X) error {
+	keys := make([]string, 0, len(x))
+	for k := range x {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := encodeXMLElement(builder, k, x[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeXMLElement(builder *strings.Builder, name string, v any) error {
+	switch vv := v.(type) {
+	case []any:
+		for _, item := range vv {
+			if err := encodeXMLElement(builder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []X:
+		for _, item := range vv {
+			if err := encodeXMLElement(builder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []string:
+		for _, item := range vv {
+			if err := encodeXMLElement(builder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	builder.WriteString("<" + name + ">")
+	switch vv := v.(type) {
+	case nil:
+	case X:
+		if err := encodeXMLElements(builder, vv); err != nil {
+			return err
+		}
+	case map[string]any:
+		if err := encodeXMLElements(builder, vv); err != nil {
+			return err
+		}
+	case V:
+		x := make(X, len(vv))
+		for k, s := range vv {
+			x[k] = s
+		}
+		if err := encodeXMLElements(builder, x); err != nil {
+			return err
+		}
+	case string:
+		// 内容中的「]]>」需拆分到两个CDATA中
+		builder.WriteString("<![CDATA[")
+		builder.WriteString(strings.ReplaceAll(vv, "]]>", "]]]]><![CDATA[>"))
+		builder.WriteString("]]>")
+	default:
+		if err := xml.EscapeText(builder, []byte(fmt.Sprint(vv))); err != nil {
+			return err
+		}
+	}
+	builder.WriteString("</" + name + ">")
+
+	return nil
+}
+
+// XMLToX 将XML解析为X (支持嵌套元素)
+//
+//	有子元素的节点 -> X；无子元素的节点 -> string；同名重复元素 -> []any
+//	注意：仅出现一次的元素不会被解析为切片，如：Articles.item 仅一项时为X
+func XMLToX(b []byte) (X, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+
+	// 定位根节点
+	for {
+		tk, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return X{}, nil
+			}
+			return nil, err
+		}
+		if _, ok := tk.(xml.StartElement); ok {
+			break
+		}
+	}
+
+	v, err := decodeXMLElement(d)
+	if err != nil {
+		return nil, err
+	}
+	if x, ok := v.(X); ok {
+		return x, nil
+	}
+	return X{}, nil
+}
+
+// decodeXMLElement 解析当前元素 (StartElement 已读取)，有子元素时返回X，否则返回文本
+func decodeXMLElement(d *xml.Decoder) (any, error) {
+	var (
+		buf      bytes.Buffer
+		children X
+	)
+
+	for {
+		tk, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch v := tk.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(d)
+			if err != nil {
+				return nil, err
+			}
+			if children == nil {
+				children = X{}
+			}
+			name := v.Name.Local
+			prev, ok := children[name]
+			if !ok {
+				children[name] = child
+				break
+			}
+			if list, ok := prev.([]any); ok {
+				children[name] = append(list, child)
+			} else {
+				children[name] = []any{prev, child}
+			}
+		case xml.CharData:
+			buf.Write(v)
+		case xml.EndElement:
+			if children != nil {
+				return children, nil
+			}
+			return buf.String(), nil
+		}
+	}
+}
@@ -22,3 +22,55 @@ func TestXML(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, m, r)
 }
+
+func TestXMLNested(t *testing.T) {
+	x := X{
+		"ToUserName":   "toUser",
+		"FromUserName": "fromUser",
+		"CreateTime":   12345678,
+		"MsgType":      "news",
+		"ArticleCount": 2,
+		"Articles": X{
+			"item": []X{
+				{"Title": "title1", "Description": "a]]>b", "PicUrl": "picurl", "Url": "url"},
+				{"Title": "title2", "Description": "<c&d>", "PicUrl": "picurl", "Url": "url"},
+			},
+		},
+	}
+	s, err := XToXML(x)
+	assert.Nil(t, err)
+	assert.Contains(t, s, "<CreateTime>12345678</CreateTime>")
+	assert.Contains(t, s, "<MsgType><![CDATA[news]]></MsgType>")
+
+	r, err := XMLToX([]byte(s))
+	assert.Nil(t, err)
+	assert.Equal(t, "12345678", r["CreateTime"])
+
+	items, ok := r["Articles"].(X)["item"].([]any)
+	assert.True(t, ok)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "a]]>b", items[0].(X)["Description"])
+	assert.Equal(t, "<c&d>", items[1].(X)["Description"])
+
+	// 事件推送：扫码推事件
+	event := `<xml><ToUserName><![CDATA[gh_e136c6e50636]]></ToUserName>
+<FromUserName><![CDATA[oMgHVjngRipVsoxg6TuX3vz6glDg]]></FromUserName>
+<CreateTime>1408090502</CreateTime>
+<MsgType><![CDATA[event]]></MsgType>
+<Event><![CDATA[scancode_push]]></Event>
+<EventKey><![CDATA[6]]></EventKey>
+<ScanCodeInfo><ScanType><![CDATA[qrcode]]></ScanType>
+<ScanResult><![CDATA[1]]></ScanResult>
+</ScanCodeInfo>
+</xml>`
+	r, err = XMLToX([]byte(event))
+	assert.Nil(t, err)
+	assert.Equal(t, "scancode_push", r["Event"])
+	assert.Equal(t, X{"ScanType": "qrcode", "ScanResult": "1"}, r["ScanCodeInfo"])
+
+	// 扁平解析兼容
+	v, err := XMLToValue([]byte(event))
+	assert.Nil(t, err)
+	assert.Equal(t, "scancode_push", v.Get("Event"))
+	assert.Empty(t, v.Get("ScanCodeInfo"))
+}